@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+
+	"getris/engine"
+)
+
+//// Bindings
+
+// inputLabels names the inputs in menus
+var inputLabels = map[engine.Input]string{
+	engine.Input_Pause:                  "Pause",
+	engine.Input_Hold:                   "Hold",
+	engine.Input_RotateCounterClockwise: "Rotate left",
+	engine.Input_RotateClockwise:        "Rotate right",
+	engine.Input_HardDrop:               "Hard drop",
+	engine.Input_SoftDrop:               "Soft drop",
+	engine.Input_MoveLeft:               "Move left",
+	engine.Input_MoveRight:              "Move right",
+	engine.Input_Undo:                   "Undo",
+	engine.Input_Redo:                   "Redo",
+}
+
+// bindingsScreen lists every input with its keys, and binds them to the next key pressed.
+// Changes are saved to the config when leaving the screen.
+type bindingsScreen struct {
+	menu     menu
+	settings *settings
+	back     screen
+
+	// capturing is true while waiting for a key to bind to capturingInput
+	capturing      bool
+	capturingInput engine.Input
+	// replacing is true if the key replaces every key of the input, instead of being added
+	replacing bool
+	// conflictKey is the key that was pressed, if it's bound to another input.
+	// Pressing it again moves it.
+	conflictKey int32
+
+	isChanged bool
+	// message is shown below the menu, like why the bindings couldn't be saved
+	message string
+}
+
+func newBindingsScreen(s *settings, back screen) *bindingsScreen {
+	bs := &bindingsScreen{settings: s, back: back}
+
+	var items []menuItem
+	for _, input := range engine.Inputs {
+		input := input
+		items = append(items, menuItem{
+			label: inputLabels[input],
+			value: func() string { return keysText(InverseKeyMap[input]) },
+			change: func(delta int) {
+				if delta > 0 {
+					bs.capture(input, false)
+				} else {
+					bs.bind(input, nil)
+				}
+			},
+			selected: func() screen {
+				bs.capture(input, true)
+				return bs
+			},
+		})
+	}
+	items = append(items,
+		menuItem{label: "Restore defaults", selected: func() screen {
+			setKeyMap(copyKeyMap(defaultKeyMap))
+			bs.isChanged = true
+			return bs
+		}},
+		menuItem{label: "Back", selected: bs.leave},
+	)
+
+	bs.menu = menu{title: bindingsText, items: items}
+	return bs
+}
+
+// keysText lists the names of the keys, or says there are none
+func keysText(keys []int32) string {
+	if len(keys) == 0 {
+		return "none"
+	}
+
+	names := make([]string, len(keys))
+	for i, key := range keys {
+		names[i] = keyName(key)
+	}
+	return strings.Join(names, ", ")
+}
+
+// copyKeyMap returns a copy of keyMap, so changing the bindings doesn't change it
+func copyKeyMap(keyMap map[int32]engine.Input) map[int32]engine.Input {
+	c := make(map[int32]engine.Input, len(keyMap))
+	for key, input := range keyMap {
+		c[key] = input
+	}
+
+	return c
+}
+
+// capture waits for the next key pressed, to bind it to input
+func (bs *bindingsScreen) capture(input engine.Input, replacing bool) {
+	bs.capturing = true
+	bs.capturingInput = input
+	bs.replacing = replacing
+	bs.conflictKey = 0
+	bs.message = ""
+}
+
+// bind replaces the keys of input with keys.
+// Keys bound to other inputs are moved.
+func (bs *bindingsScreen) bind(input engine.Input, keys []int32) {
+	keyMap := copyKeyMap(KeyMap)
+	for _, key := range InverseKeyMap[input] {
+		delete(keyMap, key)
+	}
+	for _, key := range keys {
+		keyMap[key] = input
+	}
+
+	setKeyMap(keyMap)
+	bs.isChanged = true
+}
+
+// leave saves the bindings if they were changed, then goes back
+func (bs *bindingsScreen) leave() screen {
+	if bs.isChanged {
+		if err := bs.settings.saveBindings(); err != nil {
+			bs.message = "Couldn't save: " + err.Error()
+			bs.isChanged = false
+			return bs
+		}
+	}
+
+	return bs.back
+}
+
+func (bs *bindingsScreen) Update() screen {
+	if !bs.capturing {
+		if rl.IsKeyPressed(rl.KeyEscape) {
+			return bs.leave()
+		}
+		if next, ok := bs.menu.update(); ok {
+			return next
+		}
+		return bs
+	}
+
+	// Only the first key pressed is bound, empty the rest of the queue
+	key := rl.GetKeyPressed()
+	for rl.GetKeyPressed() != 0 {
+	}
+	switch {
+	case key == 0:
+		return bs
+	case key == rl.KeyEscape:
+		bs.capturing = false
+		return bs
+	}
+
+	// Keys bound to another input are only moved when pressed twice
+	if other, ok := KeyMap[key]; ok && other != bs.capturingInput && key != bs.conflictKey {
+		bs.conflictKey = key
+		bs.message = fmt.Sprintf("%s is bound to %s, press it again to move it", keyName(key), inputLabels[other])
+		return bs
+	}
+
+	keys := []int32{key}
+	if !bs.replacing {
+		keys = append(InverseKeyMap[bs.capturingInput], key)
+	}
+	bs.bind(bs.capturingInput, keys)
+	bs.capturing = false
+	bs.message = ""
+
+	return bs
+}
+
+func (bs *bindingsScreen) Draw() {
+	_, screenY := gameLayout.screenSize()
+	y := -(screenY / 2) + menuLineSpacing
+	bs.menu.draw(y)
+	y += menuTitleTextSize + menuLineSpacing + int32(len(bs.menu.items))*(menuTextSize+menuLineSpacing)
+
+	hint := bindingsHintText
+	if bs.capturing {
+		hint = fmt.Sprintf("Press a key for %s, escape cancels", inputLabels[bs.capturingInput])
+	}
+	if bs.message != "" {
+		hint = bs.message
+	}
+	drawCenteredTextLine(hint, y, recordTextSize, textColor)
+}
@@ -0,0 +1,78 @@
+// Command getris-verify checks replays without a window, so it builds and runs without raylib,
+// like on a server that keeps a leaderboard.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"getris/engine"
+	"getris/replay"
+)
+
+func main() {
+	os.Exit(verify(os.Args[1:]))
+}
+
+// verify plays a replay without opening a window, and prints how it ended.
+// Returns the exit code: 0 if the replay matches its result and was played with the default rules,
+// 1 if it doesn't, 2 if it can't be read.
+func verify(args []string) int {
+	fs := flag.NewFlagSet("getris-verify", flag.ExitOnError)
+	anyRules := fs.Bool("any-rules", false, "accept replays played with rules other than the default ones")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: getris-verify [--any-rules] <replay>")
+		fmt.Fprintln(fs.Output(), "Plays the replay without a window, and checks it ends with the result it claims.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	r, err := replay.Load(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	played, err := r.Verify()
+	fmt.Printf("mode      %s %+v\n", r.Mode.Name(), r.Mode)
+	fmt.Printf("rules     %+v\n", r.Rules)
+	fmt.Printf("handling  %+v\n", r.Handling)
+	fmt.Printf("score     %d\n", played.Score)
+	fmt.Printf("lines     %d\n", played.Lines)
+	fmt.Printf("level     %d\n", played.Level)
+	fmt.Printf("time      %s\n", formatTime(engine.Duration(played.Frames)))
+	fmt.Printf("board     %016x\n", played.BoardHash)
+
+	code := 0
+	var mismatches replay.MismatchError
+	if errors.As(err, &mismatches) {
+		for _, m := range mismatches {
+			fmt.Fprintf(os.Stderr, "mismatch %s: claimed %s, played %s\n", m.Field, m.Claimed, m.Played)
+		}
+		code = 1
+	}
+
+	if !*anyRules {
+		var changes replay.RulesError
+		if errors.As(r.CheckRules(engine.DefaultRules()), &changes) {
+			for _, c := range changes {
+				fmt.Fprintf(os.Stderr, "rule %s: expected %s, played %s\n", c.Rule, c.Expected, c.Played)
+			}
+			code = 1
+		}
+	}
+
+	return code
+}
+
+// formatTime formats a duration as m:ss.mmm, like the game does
+func formatTime(d time.Duration) string {
+	return fmt.Sprintf("%d:%02d.%03d", int(d.Minutes()), int(d.Seconds())%60, d.Milliseconds()%1000)
+}
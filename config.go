@@ -0,0 +1,628 @@
+package main
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+
+	"getris/engine"
+)
+
+// configFile is the JSON config, read from the config directory or --config.
+// Every key is optional, and keys that aren't set keep their defaults.
+// Flags on the command line override the config.
+type configFile struct {
+	Handling struct {
+		DAS framesConfig `json:"das"`
+		ARR framesConfig `json:"arr"`
+		DCD framesConfig `json:"dcd"`
+		SDF int          `json:"sdf"`
+	} `json:"handling"`
+
+	Rules struct {
+		Randomizer      engine.RandomizerKind     `json:"randomizer"`
+		Rotation        engine.RotationSystemKind `json:"rotation"`
+		GenerationDelay framesConfig              `json:"generation-delay"`
+		RowClearDelay   framesConfig              `json:"row-clear-delay"`
+		LockDelay       framesConfig              `json:"lock-delay"`
+		LockReset       engine.LockResetKind      `json:"lock-reset"`
+		LockResets      int                       `json:"lock-resets"`
+		Scoring         engine.ScoringKind        `json:"scoring"`
+		Previews        int                       `json:"previews"`
+		Hold            bool                      `json:"hold"`
+	} `json:"rules"`
+
+	Visuals struct {
+		CellSize int32                  `json:"cell-size"`
+		Ghost    ghostStyle             `json:"ghost"`
+		Palette  map[string]colorConfig `json:"palette"`
+	} `json:"visuals"`
+
+	// Bindings maps each input's name to the names of its keys
+	Bindings map[string][]string `json:"bindings"`
+
+	Gamepad struct {
+		Deadzone  float32 `json:"deadzone"`
+		Threshold float32 `json:"threshold"`
+		// Bindings maps each input's name to the names of its buttons and stick directions, for every gamepad
+		Bindings map[string][]string `json:"bindings"`
+		// Controllers holds bindings for gamepads by their name, which replace the bindings above for the inputs they have
+		Controllers map[string]map[string][]string `json:"controllers"`
+	} `json:"gamepad"`
+
+	Marathon struct {
+		StartLevel int                     `json:"start-level"`
+		EndLevel   int                     `json:"end-level"`
+		Goal       engine.MarathonGoalKind `json:"goal"`
+	} `json:"marathon"`
+
+	Versus struct {
+		Attack       attackConfig `json:"attack"`
+		GarbageDelay framesConfig `json:"garbage-delay"`
+		Messiness    float64      `json:"messiness"`
+		// Bindings holds each player's keys, like the bindings above
+		Bindings [engine.VersusPlayers]map[string][]string `json:"bindings"`
+	} `json:"versus"`
+}
+
+// attackConfig is engine.AttackTable, with the names of its keys in the config
+type attackConfig struct {
+	Single          int   `json:"single"`
+	Double          int   `json:"double"`
+	Triple          int   `json:"triple"`
+	Tetris          int   `json:"tetris"`
+	TSpinMiniSingle int   `json:"t-spin-mini-single"`
+	TSpinMiniDouble int   `json:"t-spin-mini-double"`
+	TSpinSingle     int   `json:"t-spin-single"`
+	TSpinDouble     int   `json:"t-spin-double"`
+	TSpinTriple     int   `json:"t-spin-triple"`
+	BackToBack      int   `json:"back-to-back"`
+	Combo           []int `json:"combo"`
+	PerfectClear    int   `json:"perfect-clear"`
+}
+
+// check returns an error naming the first row count that's negative
+func (a attackConfig) check() error {
+	v := reflect.ValueOf(a)
+	for i := 0; i < v.NumField(); i++ {
+		name := strings.Split(v.Type().Field(i).Tag.Get("json"), ",")[0]
+
+		rows := []int{}
+		switch field := v.Field(i).Interface().(type) {
+		case int:
+			rows = append(rows, field)
+		case []int:
+			rows = field
+		}
+		for _, n := range rows {
+			if n < 0 {
+				return fmt.Errorf("versus.attack.%s: can't be negative", name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// framesConfig is a number of frames, either counted (10, "10f") or as a duration ("167ms")
+type framesConfig int
+
+func (f *framesConfig) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		text = string(data)
+	}
+
+	frames, err := engine.ParseFrames(text)
+	*f = framesConfig(frames)
+	return err
+}
+
+// colorConfig is a color written as "#RRGGBB", or "#RRGGBBAA" with alpha
+type colorConfig rl.Color
+
+func (c colorConfig) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("#%02X%02X%02X%02X", c.R, c.G, c.B, c.A)), nil
+}
+
+func (c *colorConfig) UnmarshalText(text []byte) error {
+	hex := strings.TrimPrefix(string(text), "#")
+	if len(hex) == 6 {
+		hex += "FF"
+	}
+
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || len(hex) != 8 {
+		return fmt.Errorf("invalid color %q, expected #RRGGBB or #RRGGBBAA", text)
+	}
+
+	*c = colorConfig(rl.GetColor(uint(value)))
+	return nil
+}
+
+// paletteColors maps the names of the colors in the palette to the colors they set.
+// Tetromino colors are named by their kind, and kept in tetrominoColors.
+func paletteColors() map[string]*rl.Color {
+	return map[string]*rl.Color{
+		"background":      &backgroundColor,
+		"board":           &boardColor,
+		"board-outline":   &boardOutlineColor,
+		"text":            &textColor,
+		"ahead":           &aheadTextColor,
+		"behind":          &behindTextColor,
+		"menu":            &menuTextColor,
+		"menu-selected":   &menuSelectedTextColor,
+		"garbage-waiting": &garbageWaitingColor,
+		"garbage-ready":   &garbageReadyColor,
+	}
+}
+
+// paletteKinds lists the kinds with a color in the palette
+var paletteKinds = append(engine.Kinds[:], engine.Kind_Garbage)
+
+// defaultConfigPath returns where the config is read from, without --config
+func defaultConfigPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "getris", "config.json"), nil
+}
+
+// configFlagPath finds --config in the arguments.
+// It's found before the flags are parsed, so the config can set their defaults.
+func configFlagPath(args []string) string {
+	for i, arg := range args {
+		name := strings.TrimLeft(arg, "-")
+		switch {
+		case arg == "--":
+			return ""
+		case strings.HasPrefix(name, "config="):
+			return strings.TrimPrefix(name, "config=")
+		case name == "config" && i+1 < len(args):
+			return args[i+1]
+		}
+	}
+
+	return ""
+}
+
+// config returns the settings as a config file
+func (s *settings) config() configFile {
+	var c configFile
+
+	c.Handling.DAS = framesConfig(s.handling.DAS)
+	c.Handling.ARR = framesConfig(s.handling.ARR)
+	c.Handling.DCD = framesConfig(s.handling.DCD)
+	c.Handling.SDF = s.handling.SDF
+
+	c.Rules.Randomizer = s.rules.Randomizer
+	c.Rules.Rotation = s.rules.RotationSystem
+	c.Rules.GenerationDelay = framesConfig(s.rules.GenerationDelay)
+	c.Rules.RowClearDelay = framesConfig(s.rules.RowClearDelay)
+	c.Rules.LockDelay = framesConfig(s.rules.LockDelay)
+	c.Rules.LockReset = s.rules.LockReset
+	c.Rules.LockResets = s.rules.LockResetLimit
+	c.Rules.Scoring = s.rules.Scoring
+	c.Rules.Previews = s.rules.Previews
+	c.Rules.Hold = s.rules.Hold
+
+	c.Visuals.CellSize = gameLayout.cellSize
+	c.Visuals.Ghost = drawGhostStyle
+	c.Visuals.Palette = map[string]colorConfig{}
+	for name, color := range paletteColors() {
+		c.Visuals.Palette[name] = colorConfig(*color)
+	}
+	for _, kind := range paletteKinds {
+		c.Visuals.Palette[kind.String()] = colorConfig(tetrominoColors[kind])
+	}
+
+	c.Bindings = keyMapBindings(KeyMap)
+
+	c.Gamepad.Deadzone = s.gamepad.deadzone
+	c.Gamepad.Threshold = s.gamepad.threshold
+	c.Gamepad.Bindings = gamepadBindingsConfig(s.gamepad.bindings)
+	c.Gamepad.Controllers = map[string]map[string][]string{}
+	for name, bindings := range s.gamepad.controllers {
+		c.Gamepad.Controllers[name] = gamepadBindingsConfig(bindings)
+	}
+
+	c.Marathon.StartLevel = s.marathon.StartLevel
+	c.Marathon.EndLevel = s.marathon.EndLevel
+	c.Marathon.Goal = s.marathon.Goal
+
+	c.Versus.Attack = attackConfig(s.versus.Attack)
+	c.Versus.GarbageDelay = framesConfig(s.versus.GarbageDelay)
+	c.Versus.Messiness = s.versus.Messiness
+	for i, keyMap := range s.versusKeyMaps {
+		c.Versus.Bindings[i] = keyMapBindings(keyMap)
+	}
+
+	return c
+}
+
+// keyMapBindings returns the keys of every input in keyMap, as they're written in the config
+func keyMapBindings(keyMap map[int32]engine.Input) map[string][]string {
+	inverse := inverseKeyMap(keyMap)
+
+	bindings := map[string][]string{}
+	for _, input := range engine.Inputs {
+		names := []string{}
+		for _, key := range inverse[input] {
+			names = append(names, keyName(key))
+		}
+		bindings[input.String()] = names
+	}
+
+	return bindings
+}
+
+// applyConfig checks the config, then changes the settings to match it.
+// Errors name the key that's wrong.
+func (s *settings) applyConfig(c configFile) error {
+	switch {
+	case c.Handling.SDF < 0:
+		return errors.New("handling.sdf: can't be negative")
+	case c.Rules.LockResets < 0:
+		return errors.New("rules.lock-resets: can't be negative")
+	case c.Rules.Previews < 0 || c.Rules.Previews > engine.TetrominoQueueSize:
+		return fmt.Errorf("rules.previews: must be from 0 to %d", engine.TetrominoQueueSize)
+	case c.Visuals.CellSize < minCellSize || c.Visuals.CellSize > maxCellSize:
+		return fmt.Errorf("visuals.cell-size: must be from %d to %d", minCellSize, maxCellSize)
+	case c.Gamepad.Deadzone < 0 || c.Gamepad.Deadzone >= 1:
+		return errors.New("gamepad.deadzone: must be from 0 to less than 1")
+	case c.Gamepad.Threshold <= 0 || c.Gamepad.Threshold > 1:
+		return errors.New("gamepad.threshold: must be more than 0, up to 1")
+	case c.Versus.GarbageDelay < 0:
+		return errors.New("versus.garbage-delay: can't be negative")
+	case c.Versus.Messiness < 0 || c.Versus.Messiness > 1:
+		return errors.New("versus.messiness: must be from 0 to 1")
+	}
+	marathon := engine.MarathonMode{StartLevel: c.Marathon.StartLevel, EndLevel: c.Marathon.EndLevel, Goal: c.Marathon.Goal}
+	if err := marathon.Check(); err != nil {
+		return fmt.Errorf("marathon: %w", err)
+	}
+	if err := c.Versus.Attack.check(); err != nil {
+		return err
+	}
+
+	colors := paletteColors()
+	kindColors := map[engine.Kind]rl.Color{}
+	for name, color := range c.Visuals.Palette {
+		if _, ok := colors[name]; ok {
+			continue
+		}
+		if kind, ok := parsePaletteKind(name); ok {
+			kindColors[kind] = rl.Color(color)
+			continue
+		}
+		return fmt.Errorf("visuals.palette.%s: unknown color", name)
+	}
+
+	keyMap, err := bindingsKeyMap("bindings", c.Bindings, KeyMap)
+	if err != nil {
+		return err
+	}
+
+	var versusKeyMaps [engine.VersusPlayers]map[int32]engine.Input
+	for i, bindings := range c.Versus.Bindings {
+		path := fmt.Sprintf("versus.bindings.%d", i)
+		if versusKeyMaps[i], err = bindingsKeyMap(path, bindings, s.versusKeyMaps[i]); err != nil {
+			return err
+		}
+	}
+	// Players share the keyboard, so a key can only be one player's
+	for key, input := range versusKeyMaps[1] {
+		if _, ok := versusKeyMaps[0][key]; ok {
+			return fmt.Errorf("versus.bindings.1.%s: %q is also bound for player 1", input, keyName(key))
+		}
+	}
+
+	padBindings, err := parseGamepadBindings("gamepad.bindings", c.Gamepad.Bindings)
+	if err != nil {
+		return err
+	}
+	controllers := map[string]gamepadBindings{}
+	for name, bindings := range c.Gamepad.Controllers {
+		if controllers[name], err = parseGamepadBindings("gamepad.controllers."+name, bindings); err != nil {
+			return err
+		}
+	}
+
+	s.handling.DAS = int(c.Handling.DAS)
+	s.handling.ARR = int(c.Handling.ARR)
+	s.handling.DCD = int(c.Handling.DCD)
+	s.handling.SDF = c.Handling.SDF
+
+	s.rules.Randomizer = c.Rules.Randomizer
+	s.rules.RotationSystem = c.Rules.Rotation
+	s.rules.GenerationDelay = int(c.Rules.GenerationDelay)
+	s.rules.RowClearDelay = int(c.Rules.RowClearDelay)
+	s.rules.LockDelay = int(c.Rules.LockDelay)
+	s.rules.LockReset = c.Rules.LockReset
+	s.rules.LockResetLimit = c.Rules.LockResets
+	s.rules.Scoring = c.Rules.Scoring
+	s.rules.Previews = c.Rules.Previews
+	s.rules.Hold = c.Rules.Hold
+
+	s.marathon = marathon
+
+	gameLayout = newLayout(c.Visuals.CellSize, 0)
+	drawGhostStyle = c.Visuals.Ghost
+	for name, color := range c.Visuals.Palette {
+		if target, ok := colors[name]; ok {
+			*target = rl.Color(color)
+		}
+	}
+	for kind, color := range kindColors {
+		tetrominoColors[kind] = color
+	}
+
+	setKeyMap(keyMap)
+
+	s.gamepad.deadzone = c.Gamepad.Deadzone
+	s.gamepad.threshold = c.Gamepad.Threshold
+	s.gamepad.bindings = padBindings
+	s.gamepad.controllers = controllers
+
+	s.versus.Attack = engine.AttackTable(c.Versus.Attack)
+	s.versus.GarbageDelay = int(c.Versus.GarbageDelay)
+	s.versus.Messiness = c.Versus.Messiness
+	s.versusKeyMaps = versusKeyMaps
+	return nil
+}
+
+// parsePaletteKind finds the kind a palette color is named after
+func parsePaletteKind(name string) (engine.Kind, bool) {
+	for _, kind := range paletteKinds {
+		if kind.String() == name {
+			return kind, true
+		}
+	}
+
+	return 0, false
+}
+
+// bindingsKeyMap builds a key map from the bindings in the config at path, over the current key map.
+// Inputs that aren't in the config keep their keys, unless the config binds them to something else.
+func bindingsKeyMap(path string, bindings map[string][]string, current map[int32]engine.Input) (map[int32]engine.Input, error) {
+	keyMap := map[int32]engine.Input{}
+
+	names := make([]string, 0, len(bindings))
+	for name := range bindings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		input, err := engine.ParseInput(name)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: unknown input", path, name)
+		}
+
+		for _, keyText := range bindings[name] {
+			key, err := parseKey(keyText)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %v", path, name, err)
+			}
+			if other, ok := keyMap[key]; ok && other != input {
+				return nil, fmt.Errorf("%s.%s: %q is also bound to %s", path, name, keyText, other)
+			}
+
+			keyMap[key] = input
+		}
+	}
+
+	for key, input := range current {
+		if _, isBound := keyMap[key]; isBound {
+			continue
+		}
+		if _, isInConfig := bindings[input.String()]; isInConfig {
+			continue
+		}
+		keyMap[key] = input
+	}
+
+	return keyMap, nil
+}
+
+// gamepadBindingsConfig returns gamepad bindings as they're written in the config
+func gamepadBindingsConfig(bindings gamepadBindings) map[string][]string {
+	c := map[string][]string{}
+	for _, input := range engine.Inputs {
+		controls, ok := bindings[input]
+		if !ok {
+			continue
+		}
+
+		names := []string{}
+		for _, control := range controls {
+			names = append(names, control.String())
+		}
+		c[input.String()] = names
+	}
+
+	return c
+}
+
+// parseGamepadBindings reads gamepad bindings from the config, at path.
+// Unlike keys, a control can be bound to more than one input.
+func parseGamepadBindings(path string, c map[string][]string) (gamepadBindings, error) {
+	bindings := gamepadBindings{}
+	for name, controlNames := range c {
+		input, err := engine.ParseInput(name)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: unknown input", path, name)
+		}
+
+		controls := []gamepadControl{}
+		for _, controlName := range controlNames {
+			control, err := parseGamepadControl(controlName)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %v", path, name, err)
+			}
+			controls = append(controls, control)
+		}
+		bindings[input] = controls
+	}
+
+	return bindings, nil
+}
+
+// loadConfig reads the config at path, and applies it to the settings.
+// A missing file is only an error if it was asked for with --config.
+func (s *settings) loadConfig(path string, mustExist bool) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && !mustExist {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// Only the bindings in the file replace the ones that are already set
+	c := s.config()
+	c.Bindings = map[string][]string{}
+	for i := range c.Versus.Bindings {
+		c.Versus.Bindings[i] = map[string][]string{}
+	}
+	if err := decodeConfig(data, &c); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if err := s.applyConfig(c); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	return nil
+}
+
+// writeConfig writes the settings to path as a config, without overwriting an existing file
+func (s *settings) writeConfig(path string) error {
+	data, err := json.MarshalIndent(s.config(), "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// saveBindings writes the key bindings to the config, keeping everything else in it
+func (s *settings) saveBindings() error {
+	if s.configPath == "" {
+		return errors.New("no config file")
+	}
+
+	file := map[string]json.RawMessage{}
+	data, err := os.ReadFile(s.configPath)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &file); err != nil {
+			return fmt.Errorf("%s: %w", s.configPath, err)
+		}
+	case !errors.Is(err, fs.ErrNotExist):
+		return err
+	}
+
+	if file["bindings"], err = json.Marshal(s.config().Bindings); err != nil {
+		return err
+	}
+	if data, err = json.MarshalIndent(file, "", "\t"); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.configPath), 0o755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(s.configPath, append(data, '\n'), 0o644)
+}
+
+// decodeConfig decodes data over c, one key at a time.
+// So errors name the key they're in, and unknown keys are errors instead of being ignored.
+func decodeConfig(data []byte, c *configFile) error {
+	return decodeObject("", data, reflect.ValueOf(c).Elem())
+}
+
+func decodeObject(path string, data []byte, v reflect.Value) error {
+	// Values that decode themselves, like colors, aren't objects even if they're structs
+	_, isUnmarshaler := v.Addr().Interface().(json.Unmarshaler)
+	_, isTextUnmarshaler := v.Addr().Interface().(encoding.TextUnmarshaler)
+	if isUnmarshaler || isTextUnmarshaler || (v.Kind() != reflect.Struct && v.Kind() != reflect.Map) {
+		if err := json.Unmarshal(data, v.Addr().Interface()); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		return nil
+	}
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		if path == "" {
+			return err
+		}
+		return fmt.Errorf("%s: expected an object", path)
+	}
+
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// Struct keys are its fields' JSON names, maps take any key
+	fields := map[string]reflect.Value{}
+	if v.Kind() == reflect.Struct {
+		for i := 0; i < v.NumField(); i++ {
+			name := strings.Split(v.Type().Field(i).Tag.Get("json"), ",")[0]
+			fields[name] = v.Field(i)
+		}
+	} else if v.IsNil() {
+		v.Set(reflect.MakeMap(v.Type()))
+	}
+
+	for _, key := range keys {
+		keyPath := key
+		if path != "" {
+			keyPath = path + "." + key
+		}
+
+		if v.Kind() == reflect.Map {
+			value := reflect.New(v.Type().Elem()).Elem()
+			if err := decodeObject(keyPath, object[key], value); err != nil {
+				return err
+			}
+			v.SetMapIndex(reflect.ValueOf(key), value)
+			continue
+		}
+
+		field, ok := fields[key]
+		if !ok {
+			return fmt.Errorf("%s: unknown key", keyPath)
+		}
+		if err := decodeObject(keyPath, object[key], field); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
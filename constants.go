@@ -4,67 +4,37 @@ import (
 	"time"
 
 	rl "github.com/gen2brain/raylib-go/raylib"
+
+	"getris/engine"
 )
 
 const (
 	internalScreenX int32 = 800
 	internalScreenY int32 = 450
 
-	cellSizeX int32 = 20
-	cellSizeY int32 = 20
-
-	// Main board
-	boardCellsX         int32 = 10
-	boardCellsY         int32 = 40
-	boardCellsY_Visible int32 = 20 // Only the bottom 20 lines are visible
-	boardSizeX          int32 = cellSizeX * boardCellsX
-	boardSizeY          int32 = cellSizeY * boardCellsY_Visible
-	boardBottomLeftX    int32 = -(boardSizeX / 2)
-	boardBottomLeftY    int32 = (boardSizeY / 2)
-
-	// Pos in main board
-	tetrominoGenerateX int32 = 5
-	tetrominoGenerateY int32 = 20
-
-	// Holding board
-	holdingBoardCellsX      int32 = 5
-	holdingBoardCellsY      int32 = 5
-	holdingBoardSizeX       int32 = cellSizeX * holdingBoardCellsX
-	holdingBoardSizeY       int32 = cellSizeY * holdingBoardCellsY
-	holdingBoardMargin      int32 = cellSizeX
-	holdingBoardBottomLeftX int32 = boardBottomLeftX - holdingBoardSizeX - holdingBoardMargin
-	holdingBoardBottomLeftY int32 = (boardBottomLeftY - (boardSizeY - holdingBoardSizeY))
+	// Cell sizes can be set in the config, the window grows to fit bigger boards
+	defaultCellSize int32 = 20
+	minCellSize     int32 = 10
+	maxCellSize     int32 = 40
+
+	// Holding board, left of the main board
+	holdingBoardCellsX int32 = 5
+	holdingBoardCellsY int32 = 5
 
 	// Pos in holding board
 	tetrominoHoldingX int32 = 2
 	tetrominoHoldingY int32 = 2
 
-	// Queue board
-	tetrominoQueueSize int32 = 5
-	queueBoardCellsX   int32 = 5
-	queueBoardCellsY   int32 = 4 * tetrominoQueueSize
-	queueBoardMargin   int32 = cellSizeX
-	queueBoardSizeX    int32 = cellSizeX * queueBoardCellsX
-	queueBoardSizeY    int32 = cellSizeY * queueBoardCellsY
-	queueBoardBottomX  int32 = boardBottomLeftX + boardSizeX + queueBoardMargin
-	queueBoardBottomY  int32 = boardBottomLeftY
+	// Queue board, right of the main board. Each preview is 4 cells tall.
+	queueBoardCellsX       int32 = 5
+	queueBoardCellsPerKind int32 = 4
 
 	// Pos in queue board
 	tetrominoQueueX int32 = 2
 	tetrominoQueueY int32 = 1 // Multiplied by pos in queue
 
-	softDropMultiplier float64 = 0.05 // 20 times faster
-
-	generationDelay        time.Duration = time.Millisecond * 200 // 0.2 seconds
-	rowClearDelay          time.Duration = time.Millisecond * 75  // 0.075 seconds
-	trailClearDelay        time.Duration = time.Millisecond * 50  // 0.05 seconds
-	inputLongPressInterval time.Duration = time.Millisecond * 300 // 0.3 seconds
-	inputPollingInterval   time.Duration = time.Millisecond * 10  // 0.01 seconds
-
-	// During auto repeat a tetromino should be able to move to the edge in 0.5 seconds
-	//autoRepeatInterval time.Duration = time.Millisecond * time.Duration(500/boardCellsX)
-
-	linesClearedPerLevel int = 10
+	// After a stall, don't try to catch up more than this
+	maxUnsimulatedTime time.Duration = time.Millisecond * 250 // 0.25 seconds
 
 	titleTextSize    float32 = 50.0
 	titleTextSpacing float32 = 1.0
@@ -74,33 +44,115 @@ const (
 	scoreTextSize int32  = 30.0
 	levelText     string = "LEVEL"
 	scoreText     string = "SCORE"
+	linesText     string = "LINES"
+	goalText      string = "GOAL"
+	garbageText   string = "GARBAGE"
+	gradeText     string = "GRADE"
+	timeText      string = "TIME"
+	paceText      string = "PACE"
+	bestText      string = "BEST"
+	newBestText   string = "NEW BEST"
+	newScoreText  string = "NEW"
+	finishedText  string = "FINISHED"
+
+	// Versus
+	sentText   string = "SENT"
+	winsText   string = "WINS"
+	winText    string = "WIN"
+	loseText   string = "LOSE"
+	drawnText  string = "DRAW"
+	playerText string = "PLAYER"
+
+	// Online versus
+	hostText         string = "HOST"
+	joinText         string = "JOIN"
+	youText          string = "YOU"
+	netWaitingText   string = "WAITING FOR THE OTHER PLAYER"
+	disconnectedText string = "DISCONNECTED"
+	cancelText       string = "ESCAPE: CANCEL"
+	joinHintText     string = "ENTER: JOIN   ESCAPE: CANCEL"
+
+	// Watching
+	watchingText string = "WATCHING"
 
 	scoreLineSpacing int32 = 10
 	scoreLineSizeY   int32 = scoreTextSize + scoreLineSpacing
-	scoreSizeY       int32 = scoreLineSizeY * 3 // 4 lines
 
-	scoreBottomLeftX int32 = holdingBoardBottomLeftX
-	scoreBottomLeftY int32 = holdingBoardBottomLeftY + holdingBoardMargin + scoreSizeY
+	// Menus
+	titleText      string = "GETRIS"
+	modeSelectText string = "MODE"
+	marathonText   string = "MARATHON"
+	digText        string = "DIG"
+	settingsText   string = "SETTINGS"
+	replaysText    string = "REPLAYS"
+	recordsText    string = "RECORDS"
+	bindingsText   string = "CONTROLS"
+	quitGameText   string = "BACKSPACE: MENU"
+
+	menuTitleTextSize int32 = 40
+	menuTextSize      int32 = 20
+	menuLineSpacing   int32 = 10
+	menuTopY          int32 = -(internalScreenY / 3)
+
+	bindingsHintText string = "ENTER: REPLACE   RIGHT: ADD   LEFT: CLEAR"
+
+	// Record tables
+	nameText          string = "NAME"
+	dateText          string = "DATE"
+	noRecordsText     string = "No games kept yet"
+	watchReplayText   string = "ENTER: WATCH REPLAY"
+	recordTextSize    int32  = 16
+	recordLineSpacing int32  = 4
+	recordLineSizeY   int32  = recordTextSize + recordLineSpacing
+
+	// Leaves the game for the game over screen, while paused
+	quitGameKey int32 = rl.KeyBackspace
+)
+
+// sprintLineGoals are the number of lines a sprint can be to
+var sprintLineGoals = [...]int{20, 40, 100}
 
-	levelTextX   int32 = scoreBottomLeftX
-	levelTextY   int32 = scoreBottomLeftY - (scoreLineSizeY * 3)
-	levelNumberX int32 = scoreBottomLeftX
-	levelNumberY int32 = scoreBottomLeftY - (scoreLineSizeY * 2)
+// ultraMinuteLimits are the number of minutes an ultra can last
+var ultraMinuteLimits = [...]int{1, 2, 3, 5}
 
-	scoreTextX   int32 = scoreBottomLeftX
-	scoreTextY   int32 = scoreBottomLeftY - (scoreLineSizeY)
-	scoreNumberX int32 = scoreBottomLeftX
-	scoreNumberY int32 = scoreBottomLeftY
+// digRowGoals are the number of garbage rows a dig can be to
+var digRowGoals = [...]int{10, 18, 100}
+
+// digHeights are the number of garbage rows that can be kept on the board
+var digHeights = [...]int{4, 6, 8, 10}
+
+// digMessinessPercents are the chances of the hole moving between garbage rows
+var digMessinessPercents = [...]int{0, 10, 30, 50, 100}
+
+// replaysShown is how many of the newest replays are listed
+const replaysShown int = 10
+
+// Playback speeds are multiples of real time, Up and Down double or halve the speed
+const (
+	playbackMinSpeed float64 = 0.25
+	playbackMaxSpeed float64 = 4
 )
 
+// playbackSeekFrames is how far Left and Right seek, 5 seconds
+const playbackSeekFrames int = 5 * engine.FramesPerSecond
+
+// recordRowsShown is how many entries of the mode's table are shown after a game ends
+const recordRowsShown int = 5
+
 // Pallette has to be var because the rl.Color type can't be a constant
 var (
 	// Pallette
-	ghostCellAlpha    float32  = 0.25
-	backgroundColor   rl.Color = rl.GetColor(0x3E363FFF)
-	boardColor        rl.Color = rl.GetColor(0x504850FF)
-	boardOutlineColor rl.Color = rl.Black
-	textColor         rl.Color = rl.RayWhite
+	ghostCellAlpha        float32  = 0.25
+	backgroundColor       rl.Color = rl.GetColor(0x3E363FFF)
+	boardColor            rl.Color = rl.GetColor(0x504850FF)
+	boardOutlineColor     rl.Color = rl.Black
+	textColor             rl.Color = rl.RayWhite
+	aheadTextColor        rl.Color = rl.GetColor(0x00C400FF) // Green
+	behindTextColor       rl.Color = rl.GetColor(0xF50000FF) // Red
+	menuTextColor         rl.Color = rl.LightGray
+	menuSelectedTextColor rl.Color = rl.GetColor(0xFCFC32FF) // Yellow
+	garbageWaitingColor   rl.Color = rl.GetColor(0xFFA122FF) // Orange
+	garbageReadyColor     rl.Color = rl.GetColor(0xF50000FF) // Red
 	// https://coolors.co/ffa122-fcfc32-00c400-ac17ac-f50000-5193e8-310ca9
 	oTetriminoColor rl.Color = rl.GetColor(0xFCFC32FF) // Yellow
 	iTetriminoColor rl.Color = rl.GetColor(0x5193E8FF) // Light Blue
@@ -109,4 +161,17 @@ var (
 	jTetriminoColor rl.Color = rl.GetColor(0x310CA9FF) // Dark Blue
 	sTetriminoColor rl.Color = rl.GetColor(0x00C400FF) // Green
 	zTetriminoColor rl.Color = rl.GetColor(0xF50000FF) // Red
+	garbageColor    rl.Color = rl.GetColor(0x8C8C8CFF) // Gray
+
+	// tetrominoColors maps each kind of tetromino to its color in the pallette
+	tetrominoColors = map[engine.Kind]rl.Color{
+		engine.Kind_O:       oTetriminoColor,
+		engine.Kind_I:       iTetriminoColor,
+		engine.Kind_T:       tTetriminoColor,
+		engine.Kind_L:       lTetriminoColor,
+		engine.Kind_J:       jTetriminoColor,
+		engine.Kind_S:       sTetriminoColor,
+		engine.Kind_Z:       zTetriminoColor,
+		engine.Kind_Garbage: garbageColor,
+	}
 )
@@ -0,0 +1,30 @@
+package engine
+
+import (
+	"time"
+)
+
+// The game is simulated in fixed steps,
+// every delay and interval is counted in frames
+const (
+	FramesPerSecond int           = 60
+	FrameDuration   time.Duration = time.Second / time.Duration(FramesPerSecond)
+)
+
+const (
+	// Main board
+	BoardCellsX         int32 = 10
+	BoardCellsY         int32 = 40
+	BoardCellsY_Visible int32 = 20 // Only the bottom 20 lines are visible
+
+	// Pos in main board
+	TetrominoGenerateX int32 = 5
+	TetrominoGenerateY int32 = 20
+
+	// TetrominoQueueSize is how many upcoming tetrominos are dealt ahead, and the most previews there can be
+	TetrominoQueueSize int = 5
+
+	trailClearDelay int = 3 // 0.05 seconds
+
+	linesClearedPerLevel int = 10
+)
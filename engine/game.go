@@ -0,0 +1,677 @@
+package engine
+
+import (
+	"hash/fnv"
+	"math"
+	"time"
+)
+
+type Phase int
+
+const (
+	// Generation: No active tetromino.
+	//             Wait 12 frames before spawning a new one.
+	Phase_Generation Phase = iota
+	// Falling: Active tetromino is falling until it hits something.
+	Phase_Falling
+
+	// Lock: Tetrimino has hit something.
+	//       It can still be moved until the lock delay runs out.
+	Phase_Lock
+
+	// Completion: Active tetromino has landed.
+	//             Check for full rows before moving to generation
+	Phase_Completion
+
+	// Paused: Game is paused. Can only be entered from Falling or Lock.
+	Phase_Paused
+
+	// GameOver: Game is over. Goes to end after any key press.
+	Phase_GameOver
+
+	// Finished: The goal of the mode was reached. Goes to end after any key press.
+	Phase_Finished
+
+	// End: Exit immediately.
+	Phase_End
+)
+
+type Cell struct {
+	IsFilled bool
+	IsGhost  bool
+	Kind     Kind
+}
+
+type Board [BoardCellsY][BoardCellsX]Cell
+
+// Hash returns a FNV-1a hash of the filled cells and their kinds.
+// Ghost cells are only drawn, so they don't change the hash.
+func (b *Board) Hash() uint64 {
+	h := fnv.New64a()
+	for _, row := range b {
+		for _, cell := range row {
+			kind := Kind_None
+			if cell.IsFilled {
+				kind = cell.Kind
+			}
+			h.Write([]byte{byte(kind)})
+		}
+	}
+
+	return h.Sum64()
+}
+
+// GameState is a game being played, changed one frame at a time by Step.
+// It isn't safe to share between goroutines, it's read and changed only by whatever owns it, like a Runner.
+type GameState struct {
+	ActiveTetromino  *Tetromino
+	HoldingTetromino Kind
+	// TetrominoQueue holds the upcoming kinds, the next one is first
+	TetrominoQueue [TetrominoQueueSize]Kind
+	Board          Board
+	Phase          Phase
+
+	Rules          Rules
+	Handling       Handling
+	Mode           Mode
+	randomizer     Randomizer
+	rotationSystem RotationSystem
+	scoringRule    ScoringRule
+
+	linesCleared int
+	Score        int
+	// LastClear describes the last lock that cleared lines, or was a T-spin
+	LastClear Clear
+
+	IsDone bool
+
+	// frames counts the frames played, not counting the pause or game over
+	frames int
+	// lineFrames holds the frame each line was cleared on, in order
+	lineFrames []int
+
+	// garbageRNG places the holes in garbage rows
+	garbageRNG rng
+	// garbageHole is the column of the hole in the last garbage row added
+	garbageHole int32
+	// garbageAdded counts the garbage rows added to the board
+	garbageAdded int
+	// incomingGarbage holds the garbage sent by the opponent in versus, oldest first
+	incomingGarbage []PendingGarbage
+	// outgoingGarbage counts the rows to send to the opponent, after cancelling incoming garbage
+	outgoingGarbage int
+	// garbageSent counts the rows sent to the opponent
+	garbageSent int
+
+	// masterLevel is the level counted by MasterMode
+	masterLevel int
+
+	// undoHistory holds a snapshot from every spawn, in modes that allow undo
+	undoHistory []Snapshot
+	// redoHistory holds the snapshots that were undone, the next one to redo is last
+	redoHistory []Snapshot
+
+	// phaseTimer counts the frames spent waiting in the current phase
+	phaseTimer int
+	// dropTimer adds up gravity every frame, the active tetromino falls when it reaches a cell
+	dropTimer int
+	// timing is how fast the game runs on this frame, decided by the mode
+	timing Timing
+	// spawnDelay is the number of frames to wait before the next tetromino spawns
+	spawnDelay int
+	// trailTimer counts down the frames until the hard drop trail is cleared
+	trailTimer int
+	// rowsToDelete holds the completed rows while they are shown being cleared
+	rowsToDelete []int32
+	// isSoftDropping is true while soft drop is held, in any phase
+	isSoftDropping bool
+	// isLeftHeld and isRightHeld are true while move left or right are held, in any phase
+	isLeftHeld, isRightHeld bool
+	// shiftDirection is the direction auto shift moves in, -1 for left and 1 for right
+	shiftDirection int32
+	// dasTimer counts the frames shiftDirection has been held
+	dasTimer int
+	// dasCutTimer counts down the frames auto shift waits after a spawn or rotation
+	dasCutTimer int
+	// unpausedPhase is the phase to go back to after the pause
+	unpausedPhase Phase
+
+	// lockTimer counts the frames the active tetromino has been resting on something
+	lockTimer int
+	// lockResets counts how many times the lock delay was restarted since the last new lowest row
+	lockResets int
+	// lowestY is the lowest row the active tetromino has reached
+	lowestY int32
+
+	// lastMoveWasRotation is true if the active tetromino's last successful move was a rotation
+	lastMoveWasRotation bool
+	// lastKick is the kick used by the last rotation
+	lastKick int
+	// lockTSpin is the T-spin found when the active tetromino locked
+	lockTSpin TSpin
+	// combo counts the locks in a row that cleared lines, starting from -1
+	combo int
+	// isBackToBackReady is true if the last lock that cleared lines was difficult
+	isBackToBackReady bool
+}
+
+// NewGameState starts a game.
+// Games with the same rules, mode and seed deal the same tetrominos and garbage.
+func NewGameState(rules Rules, handling Handling, mode Mode, seed int64) *GameState {
+	gs := &GameState{}
+	gs.Phase = Phase_Generation
+	gs.IsDone = false
+	gs.Rules = rules
+	gs.Handling = handling
+	gs.Mode = mode
+	gs.randomizer = NewRandomizer(rules.Randomizer, seed)
+	gs.garbageRNG = rng{state: uint64(seed) ^ garbageSeed}
+	gs.rotationSystem = NewRotationSystem(rules.RotationSystem)
+	gs.scoringRule = NewScoringRule(rules.Scoring)
+	gs.combo = -1
+
+	// Initialize the tetromino queue
+	for i := 0; i < len(gs.TetrominoQueue); i++ {
+		gs.TetrominoQueue[i] = gs.randomizer.Next()
+	}
+
+	gs.Mode.Start(gs)
+	gs.timing = gs.Mode.Timing(gs)
+	gs.spawnDelay = gs.timing.ARE
+
+	return gs
+}
+
+func (gs *GameState) Level() int {
+	return gs.Mode.Level(gs)
+}
+
+// Previews returns the upcoming kinds that can be seen, the next one is first
+func (gs *GameState) Previews() []Kind {
+	previews := gs.Rules.Previews
+	if previews < 0 {
+		previews = 0
+	}
+	if previews > len(gs.TetrominoQueue) {
+		previews = len(gs.TetrominoQueue)
+	}
+
+	return gs.TetrominoQueue[:previews]
+}
+
+func (gs *GameState) LinesCleared() int {
+	return gs.linesCleared
+}
+
+// Frames returns the number of frames played, not counting the pause or game over
+func (gs *GameState) Frames() int {
+	return gs.frames
+}
+
+// Time returns how long the game has been played, not counting the pause or game over
+func (gs *GameState) Time() time.Duration {
+	return Duration(gs.frames)
+}
+
+// LineFrames returns the frame each line was cleared on, in order
+func (gs *GameState) LineFrames() []int {
+	return gs.lineFrames
+}
+
+// DropInterval returns the number of frames between each drop of the active tetromino
+func (gs *GameState) DropInterval(multiplier float64) int {
+	// Formula taken from Tetris Guide 2009, added multiplier
+	level := float64(gs.Level() - 1)
+	interval := math.Pow((0.8-(level*0.007)), level) * multiplier
+	frames := int(math.Round(interval * float64(FramesPerSecond)))
+	if frames < 1 {
+		// Can't fall more than one cell each frame
+		return 1
+	}
+
+	return frames
+}
+
+// PreviewTetromino creates a tetromino the way it will spawn, at the given origin.
+// Intended for drawing the queue and holding boards.
+func (gs *GameState) PreviewTetromino(kind Kind, originX, originY int32) *Tetromino {
+	t := gs.rotationSystem.Spawn(kind)
+	t.OriginX = originX
+	t.OriginY = originY
+	return t
+}
+
+//// Tetromino Actions
+
+func (gs *GameState) ActiveTetrominoDown() (didCollide bool) {
+	gs.ActiveTetromino.OriginY -= 1
+
+	didCollide = gs.ActiveTetromino.CheckCollision(&gs.Board)
+	if didCollide {
+		gs.ActiveTetromino.OriginY += 1
+	} else {
+		gs.lastMoveWasRotation = false
+	}
+
+	if gs.ActiveTetromino.OriginY < gs.lowestY {
+		// A new lowest row always restarts the lock delay
+		gs.lowestY = gs.ActiveTetromino.OriginY
+		gs.lockTimer = 0
+		gs.lockResets = 0
+	}
+
+	return didCollide
+}
+
+func (gs *GameState) ActiveTetrominoLeft() (didCollide bool) {
+	gs.ActiveTetromino.OriginX -= 1
+
+	didCollide = gs.ActiveTetromino.CheckCollision(&gs.Board)
+	if didCollide {
+		gs.ActiveTetromino.OriginX += 1
+	} else {
+		gs.lastMoveWasRotation = false
+	}
+
+	return didCollide
+}
+
+func (gs *GameState) ActiveTetrominoRight() (didCollide bool) {
+	gs.ActiveTetromino.OriginX += 1
+
+	didCollide = gs.ActiveTetromino.CheckCollision(&gs.Board)
+	if didCollide {
+		gs.ActiveTetromino.OriginX -= 1
+	} else {
+		gs.lastMoveWasRotation = false
+	}
+
+	return didCollide
+}
+
+func (gs *GameState) ActiveTetrominoRotateClockwise() (didCollide bool) {
+	return !gs.rotateActiveTetromino(true)
+}
+
+func (gs *GameState) ActiveTetrominoRotateCounterClockwise() (didCollide bool) {
+	return !gs.rotateActiveTetromino(false)
+}
+
+func (gs *GameState) rotateActiveTetromino(clockwise bool) bool {
+	kick, ok := gs.rotationSystem.Rotate(gs.ActiveTetromino, &gs.Board, clockwise)
+	if ok {
+		gs.lastMoveWasRotation = true
+		gs.lastKick = kick
+	}
+
+	return ok
+}
+
+func (gs *GameState) ActiveTetrominoHold() (shouldGenerate bool) {
+	held := gs.HoldingTetromino
+	gs.HoldingTetromino = gs.ActiveTetromino.Kind
+
+	if held != Kind_None {
+		gs.ActiveTetromino = gs.rotationSystem.Spawn(held)
+		gs.resetLockDelay()
+		return false
+	}
+
+	gs.ActiveTetromino = nil
+	return true
+}
+
+func (gs *GameState) ActiveTetrominoHardDown() {
+	cells := 0
+	for {
+		gs.ActiveTetromino.OriginY -= 1
+
+		if gs.ActiveTetromino.CheckCollision(&gs.Board) {
+			gs.ActiveTetromino.OriginY += 1
+			break
+		}
+
+		gs.ActiveTetromino.CommitTrailToBoard(&gs.Board)
+		cells++
+	}
+
+	if cells > 0 {
+		gs.lastMoveWasRotation = false
+	}
+	gs.Score += gs.scoringRule.DropPoints(cells, true)
+	gs.trailTimer = trailClearDelay
+}
+
+// GhostTetromino returns the active tetromino where it would land if hard dropped.
+// Returns nil if there is no active tetromino.
+func (gs *GameState) GhostTetromino() *Tetromino {
+	if gs.ActiveTetromino == nil {
+		return nil
+	}
+
+	ghost := *gs.ActiveTetromino
+	for !ghost.CheckCollision(&gs.Board) {
+		ghost.OriginY -= 1
+	}
+	ghost.OriginY += 1
+
+	return &ghost
+}
+
+// IsActiveTetrominoResting returns true if the active tetromino can't fall any further
+func (gs *GameState) IsActiveTetrominoResting() bool {
+	below := *gs.ActiveTetromino
+	below.OriginY -= 1
+	return below.CheckCollision(&gs.Board)
+}
+
+func (gs *GameState) resetLockDelay() {
+	gs.lockTimer = 0
+	gs.lockResets = 0
+	gs.lowestY = gs.ActiveTetromino.OriginY
+}
+
+// activeTetrominoMoved restarts the lock delay after a move or rotation, if the rules allow it
+func (gs *GameState) activeTetrominoMoved() {
+	if gs.Phase != Phase_Lock {
+		return
+	}
+
+	switch gs.Rules.LockReset {
+	case LockReset_Move:
+		if gs.lockResets < gs.Rules.LockResetLimit {
+			gs.lockTimer = 0
+			gs.lockResets++
+		}
+	case LockReset_Infinite:
+		gs.lockTimer = 0
+	case LockReset_Step:
+		// Only a new lowest row restarts the lock delay
+	}
+}
+
+// lockActiveTetromino commits the active tetromino to the board
+func (gs *GameState) lockActiveTetromino() {
+	gs.lockTSpin = gs.detectTSpin()
+	gs.ActiveTetromino.CommitToBoard(&gs.Board)
+	gs.ActiveTetromino = nil
+	gs.Phase = Phase_Completion
+}
+
+func (gs *GameState) clearTrail() {
+	for i := int32(0); i < BoardCellsY; i++ {
+		for j := int32(0); j < BoardCellsX; j++ {
+			gs.Board[i][j].IsGhost = false
+		}
+	}
+}
+
+//// Phases
+// Each phase is called once per frame, and moves to another phase when it's ready
+
+func (gs *GameState) GenerationPhase() {
+	// Spawn a new tetromino, after a delay
+	if gs.phaseTimer < gs.spawnDelay {
+		gs.phaseTimer++
+		return
+	}
+	gs.phaseTimer = 0
+
+	gs.ActiveTetromino = gs.rotationSystem.Spawn(gs.TetrominoQueue[0])
+
+	// Move all tetrominos in the queue up
+	copy(gs.TetrominoQueue[:], gs.TetrominoQueue[1:])
+
+	// Generate a new tetromino
+	gs.TetrominoQueue[TetrominoQueueSize-1] = gs.randomizer.Next()
+
+	// Drop the tetromino once to check for collisions
+	gameOver := gs.ActiveTetromino.CheckCollision(&gs.Board)
+
+	if gameOver && gs.Mode.ToppedOut(gs) {
+		gs.Phase = Phase_GameOver
+		return
+	}
+	gs.dropTimer = 0
+	gs.dasCutTimer = gs.Handling.DCD
+	gs.lastMoveWasRotation = false
+	gs.resetLockDelay()
+	gs.Phase = Phase_Falling
+	gs.Mode.Spawned(gs)
+}
+
+// controlActiveTetromino applies the inputs that control the active tetromino.
+// Used by both the falling and lock phases.
+func (gs *GameState) controlActiveTetromino(inputs []InputEvent) {
+	phase := gs.Phase
+
+	for _, event := range inputs {
+		if gs.Phase != phase {
+			// An earlier input ended the phase
+			return
+		}
+
+		if event.Action != Action_Down {
+			if event.Input == Input_Pause && event.Action == Action_Up {
+				gs.unpausedPhase = gs.Phase
+				gs.Phase = Phase_Paused
+			}
+			continue
+		}
+
+		switch event.Input {
+		case Input_Hold:
+			if !gs.Rules.Hold {
+				break
+			}
+			if shouldGenerate := gs.ActiveTetrominoHold(); shouldGenerate {
+				gs.spawnDelay = gs.timing.ARE
+				gs.Phase = Phase_Generation
+			} else {
+				gs.Phase = Phase_Falling
+			}
+		case Input_MoveLeft:
+			if didCollide := gs.ActiveTetrominoLeft(); !didCollide {
+				gs.activeTetrominoMoved()
+			}
+		case Input_MoveRight:
+			if didCollide := gs.ActiveTetrominoRight(); !didCollide {
+				gs.activeTetrominoMoved()
+			}
+		case Input_RotateClockwise:
+			if didCollide := gs.ActiveTetrominoRotateClockwise(); !didCollide {
+				gs.activeTetrominoMoved()
+				gs.dasCutTimer = gs.Handling.DCD
+			}
+		case Input_RotateCounterClockwise:
+			if didCollide := gs.ActiveTetrominoRotateCounterClockwise(); !didCollide {
+				gs.activeTetrominoMoved()
+				gs.dasCutTimer = gs.Handling.DCD
+			}
+		case Input_HardDrop:
+			gs.ActiveTetrominoHardDown()
+			gs.lockActiveTetromino()
+		case Input_Undo:
+			gs.undo()
+		case Input_Redo:
+			gs.redo()
+		}
+	}
+}
+
+func (gs *GameState) FallingPhase(inputs []InputEvent) {
+	gs.controlActiveTetromino(inputs)
+	if gs.Phase != Phase_Falling {
+		return
+	}
+	gs.autoShift()
+
+	if isSoftDropping := gs.softDrop(); !isSoftDropping {
+		gs.fall(gs.timing.Gravity)
+	}
+
+	// Start the lock delay as soon as the tetromino touches down
+	if gs.IsActiveTetrominoResting() {
+		gs.Phase = Phase_Lock
+	}
+}
+
+func (gs *GameState) LockPhase(inputs []InputEvent) {
+	gs.controlActiveTetromino(inputs)
+	if gs.Phase != Phase_Lock {
+		return
+	}
+	gs.autoShift()
+
+	// Moved off a ledge, start falling again
+	if !gs.IsActiveTetrominoResting() {
+		gs.dropTimer = 0
+		gs.Phase = Phase_Falling
+		return
+	}
+
+	gs.lockTimer++
+	if gs.lockTimer >= gs.timing.LockDelay {
+		gs.lockActiveTetromino()
+	}
+}
+
+func (gs *GameState) CompletionPhase() {
+	if gs.rowsToDelete != nil {
+		// Small delay so the user can see the rows being deleted
+		if gs.phaseTimer < gs.timing.LineClearDelay {
+			gs.phaseTimer++
+			return
+		}
+		gs.phaseTimer = 0
+
+		lines := len(gs.rowsToDelete)
+		gs.deleteRows()
+		gs.spawnDelay = gs.timing.LineARE
+		gs.Phase = Phase_Generation
+		gs.Mode.Completed(gs, lines)
+		return
+	}
+
+	rowsToDelete := []int32{}
+
+	// Mark rows for deletion
+	for i := BoardCellsY - 1; i >= 0; i-- {
+		row := gs.Board[i]
+		isRowComplete := true
+		for _, cell := range row {
+			if !cell.IsFilled {
+				isRowComplete = false
+				break
+			}
+		}
+
+		if isRowComplete {
+			rowsToDelete = append(rowsToDelete, i)
+			// Mark cells visually as deleted
+			for j := int32(0); j < BoardCellsX; j++ {
+				gs.Board[i][j].IsFilled = false
+				gs.Board[i][j].IsGhost = true
+			}
+		}
+	}
+
+	shouldDeleteRows := len(rowsToDelete) > 0
+
+	gs.scoreLock(len(rowsToDelete))
+
+	if !shouldDeleteRows {
+		gs.spawnDelay = gs.timing.ARE
+		gs.Phase = Phase_Generation
+		gs.Mode.Completed(gs, 0)
+		return
+	}
+
+	gs.rowsToDelete = rowsToDelete
+}
+
+// deleteRows removes the rows marked by CompletionPhase
+func (gs *GameState) deleteRows() {
+	// Start with the topmost row to delete
+	for i := 0; i < len(gs.rowsToDelete); i++ {
+		// starting from current row, move all rows above down
+		for j := int32(gs.rowsToDelete[i]); j < BoardCellsY-1; j++ {
+			gs.Board[j] = gs.Board[j+1]
+		}
+
+		// clear the top row
+		gs.Board[BoardCellsY-1] = [BoardCellsX]Cell{}
+	}
+
+	gs.rowsToDelete = nil
+}
+
+func (gs *GameState) PausedPhase(inputs []InputEvent) {
+	for _, event := range inputs {
+		if event.Input == Input_Pause && event.Action == Action_Up {
+			gs.Phase = gs.unpausedPhase
+			return
+		}
+	}
+}
+
+func (gs *GameState) GameOverPhase(inputs []InputEvent) {
+	for _, event := range inputs {
+		if event.Action == Action_Down {
+			gs.Phase = Phase_End
+			return
+		}
+	}
+}
+
+//// Main loop
+
+// Step advances the game by one frame, given the inputs that happened since the last step.
+// The same inputs on the same frames always lead to the same game.
+func (gs *GameState) Step(inputs []InputEvent) {
+	gs.timing = gs.Mode.Timing(gs)
+	gs.trackHeldInputs(inputs)
+
+	switch gs.Phase {
+	case Phase_Paused, Phase_GameOver, Phase_Finished, Phase_End:
+	default:
+		gs.frames++
+	}
+
+	if gs.trailTimer > 0 && gs.Phase != Phase_Paused {
+		gs.trailTimer--
+		if gs.trailTimer == 0 {
+			gs.clearTrail()
+		}
+	}
+
+	switch gs.Phase {
+	case Phase_Generation:
+		gs.GenerationPhase()
+	case Phase_Falling:
+		gs.FallingPhase(inputs)
+	case Phase_Lock:
+		gs.LockPhase(inputs)
+	case Phase_Completion:
+		gs.CompletionPhase()
+	case Phase_Paused:
+		gs.PausedPhase(inputs)
+	case Phase_GameOver, Phase_Finished:
+		gs.GameOverPhase(inputs)
+	case Phase_End:
+		gs.IsDone = true
+	}
+
+	switch gs.Phase {
+	case Phase_Paused, Phase_GameOver, Phase_Finished, Phase_End:
+	default:
+		// Stop the clock on the frame the goal was reached
+		if gs.Mode.IsFinished(gs) {
+			gs.Phase = Phase_Finished
+		}
+	}
+
+	if gs.Phase != Phase_Paused {
+		gs.chargeAutoShift()
+	}
+}
@@ -0,0 +1,130 @@
+package engine
+
+import (
+	"testing"
+)
+
+// stillMode is marathon without gravity, so only inputs move the active tetromino
+type stillMode struct {
+	MarathonMode
+}
+
+func (stillMode) Timing(gs *GameState) Timing {
+	t := gs.standardTiming()
+	t.Gravity = Gravity{Cells: 0, Frames: 1}
+	return t
+}
+
+// spawned starts a game without gravity, and steps it until a T spawns in the middle of the board
+func spawned(t *testing.T, rules Rules, handling Handling) *GameState {
+	t.Helper()
+
+	gs := NewGameState(rules, handling, stillMode{MarathonMode{StartLevel: 1}}, 1)
+	for i := 0; gs.Phase != Phase_Falling; i++ {
+		if i > 100 {
+			t.Fatalf("nothing spawned in %d frames", i)
+		}
+		gs.Step(nil)
+	}
+
+	gs.ActiveTetromino = gs.PreviewTetromino(Kind_T, 4, gs.ActiveTetromino.OriginY)
+	gs.resetLockDelay()
+	return gs
+}
+
+// tap presses and releases an input in the same frame
+func tap(input Input) []InputEvent {
+	return []InputEvent{{Input: input, Action: Action_Down}, {Input: input, Action: Action_Up}}
+}
+
+func TestLockDelay(t *testing.T) {
+	tests := []struct {
+		name  string
+		reset LockResetKind
+		limit int
+		// want is the number of frames resting before the tetromino locks, tapping left or right every 10
+		want int
+	}{
+		{"move", LockReset_Move, 3, 59},
+		{"move without resets", LockReset_Move, 0, 30},
+		{"step", LockReset_Step, 3, 30},
+		{"infinite", LockReset_Infinite, 0, -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := DefaultRules()
+			rules.LockDelay = 30
+			rules.LockReset = tt.reset
+			rules.LockResetLimit = tt.limit
+			gs := spawned(t, rules, DefaultHandling())
+
+			for !gs.ActiveTetrominoDown() {
+			}
+			gs.Step(nil)
+			if gs.Phase != Phase_Lock {
+				t.Fatalf("resting in phase %d, want the lock phase", gs.Phase)
+			}
+
+			got := -1
+			for frame := 1; frame <= 200; frame++ {
+				var inputs []InputEvent
+				switch frame % 20 {
+				case 0:
+					inputs = tap(Input_MoveLeft)
+				case 10:
+					inputs = tap(Input_MoveRight)
+				}
+
+				gs.Step(inputs)
+				if gs.Phase != Phase_Lock {
+					got = frame
+					break
+				}
+			}
+			if got != tt.want {
+				t.Errorf("locked after %d frames, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLockDelayNewLowestRow(t *testing.T) {
+	rules := DefaultRules()
+	rules.LockDelay = 30
+	rules.LockReset = LockReset_Step
+	gs := spawned(t, rules, DefaultHandling())
+
+	// A ledge one row above the floor, under the left of the T
+	floor := int32(1)
+	for x := int32(0); x <= 3; x++ {
+		gs.Board[0][x] = Cell{IsFilled: true, Kind: Kind_Garbage}
+	}
+	for !gs.ActiveTetrominoDown() {
+	}
+	if gs.ActiveTetromino.OriginY != floor {
+		t.Fatalf("resting at row %d, want %d", gs.ActiveTetromino.OriginY, floor)
+	}
+
+	// Rest for a while, then slide off the ledge, which drops a row and restarts the lock delay
+	gs.Step(nil)
+	for frame := 0; frame < 20; frame++ {
+		gs.Step(nil)
+	}
+	gs.Step(tap(Input_MoveRight))
+	if gs.Phase != Phase_Falling {
+		t.Fatalf("in phase %d off the ledge, want falling", gs.Phase)
+	}
+	for !gs.ActiveTetrominoDown() {
+	}
+	gs.Step(nil)
+
+	frames := 0
+	for gs.Phase == Phase_Lock {
+		gs.Step(nil)
+		frames++
+	}
+	if frames != rules.LockDelay {
+		t.Errorf("locked %d frames after reaching a new lowest row, want %d", frames, rules.LockDelay)
+	}
+}
@@ -0,0 +1,85 @@
+package engine
+
+// garbageSeed is mixed into the game's seed for the garbage,
+// so holes don't follow the tetrominos that are dealt
+const garbageSeed uint64 = 0x6A09E667F3BCC909
+
+// pushUp moves every row up by one, and puts row at the bottom.
+// Returns true if a filled cell was pushed off the top.
+func (b *Board) pushUp(row [BoardCellsX]Cell) (toppedOut bool) {
+	for _, cell := range b[BoardCellsY-1] {
+		if cell.IsFilled {
+			toppedOut = true
+			break
+		}
+	}
+
+	copy(b[1:], b[:BoardCellsY-1])
+	b[0] = row
+
+	return toppedOut
+}
+
+// AddGarbage pushes rows of garbage up from the bottom of the board, each with one hole.
+// messiness is the chance, from 0 to 1, of the hole moving between rows.
+// The active tetromino is pushed up too, if the garbage would overlap it.
+func (gs *GameState) AddGarbage(rows int, messiness float64) {
+	toppedOut := false
+	for i := 0; i < rows; i++ {
+		switch {
+		case gs.garbageAdded == 0:
+			gs.garbageHole = int32(gs.garbageRNG.intn(int(BoardCellsX)))
+		case gs.garbageRNG.float64() < messiness:
+			// Move the hole anywhere but where it was
+			hole := int32(gs.garbageRNG.intn(int(BoardCellsX - 1)))
+			if hole >= gs.garbageHole {
+				hole++
+			}
+			gs.garbageHole = hole
+		}
+
+		var row [BoardCellsX]Cell
+		for x := range row {
+			if int32(x) != gs.garbageHole {
+				row[x] = Cell{IsFilled: true, Kind: Kind_Garbage}
+			}
+		}
+
+		if gs.Board.pushUp(row) {
+			toppedOut = true
+		}
+		gs.garbageAdded++
+	}
+
+	if gs.ActiveTetromino != nil {
+		for gs.ActiveTetromino.CheckCollision(&gs.Board) {
+			gs.ActiveTetromino.OriginY++
+			if gs.ActiveTetromino.OriginY >= BoardCellsY {
+				// Nowhere left to go
+				toppedOut = true
+				break
+			}
+		}
+		gs.lowestY = gs.ActiveTetromino.OriginY
+	}
+
+	if toppedOut {
+		gs.ActiveTetromino = nil
+		gs.Phase = Phase_GameOver
+	}
+}
+
+// garbageRowsLeft counts the rows with garbage in them
+func (gs *GameState) garbageRowsLeft() int {
+	count := 0
+	for _, row := range gs.Board {
+		for _, cell := range row {
+			if cell.IsFilled && cell.Kind == Kind_Garbage {
+				count++
+				break
+			}
+		}
+	}
+
+	return count
+}
@@ -0,0 +1,155 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Handling is how held inputs behave. Unlike the rules, every player tunes it to their taste.
+type Handling struct {
+	// DAS (Delayed Auto Shift) is the number of frames left or right is held before it repeats
+	DAS int
+	// ARR (Auto Repeat Rate) is the number of frames between each repeated move.
+	// 0 moves straight to the wall.
+	ARR int
+	// DCD (DAS Cut Delay) is the number of frames auto shift waits after a spawn or rotation
+	DCD int
+	// SDF (Soft Drop Factor) is how many times faster than gravity a soft drop falls.
+	// 0 drops straight to the floor.
+	SDF int
+}
+
+func DefaultHandling() Handling {
+	return Handling{
+		DAS: 10, // ~167 milliseconds
+		ARR: 2,  // ~33 milliseconds
+		DCD: 0,
+		SDF: 20,
+	}
+}
+
+// ParseFrames reads a number of frames, either counted ("10", "10f") or as a duration ("167ms")
+func ParseFrames(s string) (int, error) {
+	counted := strings.TrimSuffix(s, "f")
+	if frames, err := strconv.Atoi(counted); err == nil {
+		if frames < 0 {
+			return 0, fmt.Errorf("negative frames %q", s)
+		}
+		return frames, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid frames %q, expected a number of frames or a duration", s)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("negative duration %q", s)
+	}
+
+	return Frames(d), nil
+}
+
+// trackHeldInputs keeps track of held inputs, in every phase.
+// So auto shift charges during spawn and line clear delays, and soft drop is still held after a spawn.
+func (gs *GameState) trackHeldInputs(inputs []InputEvent) {
+	for _, event := range inputs {
+		isDown := event.Action == Action_Down
+
+		switch event.Input {
+		case Input_SoftDrop:
+			gs.isSoftDropping = isDown
+		case Input_MoveLeft:
+			gs.isLeftHeld = isDown
+			gs.updateShiftDirection(-1, isDown, gs.isRightHeld)
+		case Input_MoveRight:
+			gs.isRightHeld = isDown
+			gs.updateShiftDirection(1, isDown, gs.isLeftHeld)
+		}
+	}
+}
+
+// updateShiftDirection makes the last pressed direction win,
+// falling back to the other direction if it's still held.
+func (gs *GameState) updateShiftDirection(direction int32, isDown bool, isOtherHeld bool) {
+	if isDown {
+		gs.shiftDirection = direction
+		gs.dasTimer = 0
+		return
+	}
+
+	if gs.shiftDirection != direction {
+		return
+	}
+
+	gs.shiftDirection = 0
+	if isOtherHeld {
+		gs.shiftDirection = -direction
+		gs.dasTimer = 0
+	}
+}
+
+// chargeAutoShift advances the auto shift timers by one frame
+func (gs *GameState) chargeAutoShift() {
+	if gs.shiftDirection != 0 {
+		gs.dasTimer++
+	}
+	if gs.dasCutTimer > 0 {
+		gs.dasCutTimer--
+	}
+}
+
+// autoShift moves the active tetromino while left or right is held
+func (gs *GameState) autoShift() {
+	if gs.shiftDirection == 0 || gs.dasTimer < gs.timing.DAS || gs.dasCutTimer > 0 {
+		return
+	}
+
+	move := gs.ActiveTetrominoLeft
+	if gs.shiftDirection > 0 {
+		move = gs.ActiveTetrominoRight
+	}
+
+	if gs.Handling.ARR == 0 {
+		moved := false
+		for !move() {
+			moved = true
+		}
+		if moved {
+			gs.activeTetrominoMoved()
+		}
+		return
+	}
+
+	if (gs.dasTimer-gs.timing.DAS)%gs.Handling.ARR == 0 {
+		if didCollide := move(); !didCollide {
+			gs.activeTetrominoMoved()
+		}
+	}
+}
+
+// softDrop moves the active tetromino down faster than gravity, while soft drop is held.
+// Returns true if it handled falling for this frame.
+func (gs *GameState) softDrop() bool {
+	if !gs.isSoftDropping {
+		return false
+	}
+
+	if gs.Handling.SDF == 0 {
+		for !gs.ActiveTetrominoDown() {
+			gs.Score += gs.scoringRule.DropPoints(1, false)
+		}
+		return true
+	}
+
+	gravity := gs.timing.Gravity
+	if gravity.Cells == 0 {
+		// Without gravity, soft drop goes SDF times as fast as level 1
+		gravity = Gravity{Cells: 1, Frames: FramesPerSecond}
+	}
+	gravity.Cells *= gs.Handling.SDF
+	cells := gs.fall(gravity)
+	gs.Score += gs.scoringRule.DropPoints(cells, false)
+	return true
+}
@@ -0,0 +1,145 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+)
+
+// moves returns the frames the active tetromino moved sideways on, and where it ended up
+func moves(gs *GameState, frames int, inputs map[int][]InputEvent) ([]int, int32) {
+	var moved []int
+	for frame := 0; frame < frames; frame++ {
+		x := gs.ActiveTetromino.OriginX
+		gs.Step(inputs[frame])
+		if gs.ActiveTetromino.OriginX != x {
+			moved = append(moved, frame)
+		}
+	}
+
+	return moved, gs.ActiveTetromino.OriginX
+}
+
+func TestAutoShift(t *testing.T) {
+	tests := []struct {
+		name     string
+		das, arr int
+		// want is the frames right moves on, holding it from frame 0. A T can move 4 times from the middle.
+		want []int
+	}{
+		{"das 10 arr 2", 10, 2, []int{0, 10, 12, 14}},
+		{"das 6 arr 2", 6, 2, []int{0, 6, 8, 10}},
+		{"das 10 arr 1", 10, 1, []int{0, 10, 11, 12}},
+		{"das 10 arr 3", 10, 3, []int{0, 10, 13, 16}},
+		// To the wall at once
+		{"das 10 arr 0", 10, 0, []int{0, 10}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handling := DefaultHandling()
+			handling.DAS = tt.das
+			handling.ARR = tt.arr
+			gs := spawned(t, DefaultRules(), handling)
+
+			got, x := moves(gs, 30, map[int][]InputEvent{0: {press(Input_MoveRight)}})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("moved on frames %v, want %v", got, tt.want)
+			}
+			if x != 8 {
+				t.Errorf("ended at %d, want the wall at 8", x)
+			}
+		})
+	}
+}
+
+func TestAutoShiftReleased(t *testing.T) {
+	gs := spawned(t, DefaultRules(), DefaultHandling())
+
+	// Released before DAS, so it only moves once
+	got, _ := moves(gs, 30, map[int][]InputEvent{
+		0: {press(Input_MoveRight)},
+		9: {{Input: Input_MoveRight, Action: Action_Up}},
+	})
+	if !reflect.DeepEqual(got, []int{0}) {
+		t.Errorf("moved on frames %v, want only 0", got)
+	}
+}
+
+func TestDASCutDelay(t *testing.T) {
+	tests := []struct {
+		dcd int
+		// want is the frame after a spawn that a charged auto shift first moves the new tetromino
+		want int
+	}{
+		{dcd: 0, want: 1},
+		{dcd: 1, want: 1},
+		{dcd: 5, want: 5},
+		{dcd: 12, want: 12},
+	}
+
+	for _, tt := range tests {
+		handling := DefaultHandling()
+		handling.ARR = 1
+		handling.DCD = tt.dcd
+		gs := spawned(t, DefaultRules(), handling)
+
+		// Charge auto shift to the wall, then keep holding it through the next spawn
+		gs.Step([]InputEvent{press(Input_MoveRight)})
+		for frame := 0; frame < 20; frame++ {
+			gs.Step(nil)
+		}
+		gs.Step(tap(Input_HardDrop))
+		for gs.Phase != Phase_Falling {
+			gs.Step(nil)
+		}
+
+		x := gs.ActiveTetromino.OriginX
+		got := 0
+		for gs.ActiveTetromino.OriginX == x && got < 100 {
+			gs.Step(nil)
+			got++
+		}
+		if got != tt.want {
+			t.Errorf("DCD %d: moved %d frames after the spawn, want %d", tt.dcd, got, tt.want)
+		}
+	}
+}
+
+func TestSoftDrop(t *testing.T) {
+	tests := []struct {
+		name   string
+		sdf    int
+		frames int
+		// want is the rows fallen, -1 for all the way to the floor
+		want int32
+	}{
+		// Without gravity, 20 times level 1 is a row every 3 frames
+		{"sdf 20 for 2 frames", 20, 2, 0},
+		{"sdf 20 for 3 frames", 20, 3, 1},
+		{"sdf 20 for 30 frames", 20, 30, 10},
+		{"sdf 40 for 30 frames", 40, 30, 20},
+		{"sdf 0", 0, 1, -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handling := DefaultHandling()
+			handling.SDF = tt.sdf
+			gs := spawned(t, DefaultRules(), handling)
+
+			start := gs.ActiveTetromino.OriginY
+			want := tt.want
+			if want < 0 {
+				want = start - gs.GhostTetromino().OriginY
+			}
+
+			gs.Step([]InputEvent{press(Input_SoftDrop)})
+			for frame := 1; frame < tt.frames; frame++ {
+				gs.Step(nil)
+			}
+			if fallen := start - gs.ActiveTetromino.OriginY; fallen != want {
+				t.Errorf("fell %d rows in %d frames, want %d", fallen, tt.frames, want)
+			}
+		})
+	}
+}
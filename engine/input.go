@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"fmt"
+)
+
+type Input int
+
+const (
+	Input_Pause Input = iota
+	Input_Hold
+	Input_RotateCounterClockwise
+	Input_RotateClockwise
+	Input_HardDrop
+	Input_SoftDrop
+	Input_MoveLeft
+	Input_MoveRight
+	// Undo and Redo step back and forward through placements, in modes that allow it
+	Input_Undo
+	Input_Redo
+)
+
+var inputNames = [...]string{
+	Input_Pause:                  "pause",
+	Input_Hold:                   "hold",
+	Input_RotateCounterClockwise: "rotate-ccw",
+	Input_RotateClockwise:        "rotate-cw",
+	Input_HardDrop:               "hard-drop",
+	Input_SoftDrop:               "soft-drop",
+	Input_MoveLeft:               "move-left",
+	Input_MoveRight:              "move-right",
+	Input_Undo:                   "undo",
+	Input_Redo:                   "redo",
+}
+
+// Inputs lists every input, in order
+var Inputs = [...]Input{
+	Input_Pause, Input_Hold, Input_RotateCounterClockwise, Input_RotateClockwise, Input_HardDrop,
+	Input_SoftDrop, Input_MoveLeft, Input_MoveRight, Input_Undo, Input_Redo,
+}
+
+func (k Input) String() string {
+	if k < 0 || int(k) >= len(inputNames) {
+		return fmt.Sprintf("Input(%d)", int(k))
+	}
+
+	return inputNames[k]
+}
+
+// ParseInput is the inverse of Input.String
+func ParseInput(name string) (Input, error) {
+	for k, n := range inputNames {
+		if n == name {
+			return Input(k), nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown input %q", name)
+}
+
+type Action int
+
+const (
+	Action_Down Action = iota
+	Action_Up
+)
+
+type InputEvent struct {
+	Input   Input
+	Action  Action
+	KeyCode int32
+	// Player is who the input is for in a versus match, from 0. Games with one player ignore it.
+	Player int
+}
@@ -0,0 +1,127 @@
+package engine
+
+import (
+	"time"
+)
+
+// MasterMode plays like TGM. The level goes from 0 to 999, up one for every tetromino and every line,
+// but stops before each hundred until a line is cleared.
+// Gravity reaches 20G at level 500, and the delays get shorter every section of 100 levels after that.
+type MasterMode struct{}
+
+// MasterMaxLevel is the last level, the game is finished when it's reached
+const MasterMaxLevel int = 999
+
+// masterGravity is the gravity from each level on, in 1/GravityUnit cells per frame
+var masterGravity = [...]struct{ level, cells int }{
+	{0, 4}, {30, 6}, {35, 8}, {40, 10}, {50, 12}, {60, 16}, {70, 32}, {80, 48}, {90, 64},
+	{100, 80}, {120, 96}, {140, 112}, {160, 128}, {170, 144},
+	{200, 4}, {220, 32}, {230, 64}, {233, 96}, {236, 128}, {239, 160}, {243, 192}, {247, 224}, {251, 256},
+	{300, 512}, {330, 768}, {360, 1024}, {400, 1280}, {420, 1024}, {450, 768},
+	{500, 20 * GravityUnit},
+}
+
+// masterDelays are the delays from each level on, in frames
+var masterDelays = [...]struct{ level, are, lineARE, das, lockDelay, lineClearDelay int }{
+	{0, 25, 25, 14, 30, 40},
+	{500, 25, 25, 8, 30, 25},
+	{600, 25, 16, 8, 30, 16},
+	{700, 16, 12, 8, 30, 12},
+	{800, 12, 6, 8, 30, 6},
+	{900, 12, 6, 6, 17, 6},
+}
+
+func (MasterMode) Name() string {
+	return "master"
+}
+
+func (MasterMode) Level(gs *GameState) int {
+	return gs.masterLevel
+}
+
+func (MasterMode) IsFinished(gs *GameState) bool {
+	return gs.masterLevel >= MasterMaxLevel
+}
+
+func (MasterMode) Timing(gs *GameState) Timing {
+	var t Timing
+
+	for _, g := range masterGravity {
+		if gs.masterLevel >= g.level {
+			t.Gravity = Gravity{Cells: g.cells, Frames: GravityUnit}
+		}
+	}
+
+	for _, d := range masterDelays {
+		if gs.masterLevel >= d.level {
+			t.ARE = d.are
+			t.LineARE = d.lineARE
+			t.DAS = d.das
+			t.LockDelay = d.lockDelay
+			t.LineClearDelay = d.lineClearDelay
+		}
+	}
+
+	return t
+}
+
+// Start switches to TGM's scoring, and its lock delay that only restarts on a new lowest row
+func (MasterMode) Start(gs *GameState) {
+	gs.Rules.Scoring = Scoring_TGM
+	gs.scoringRule = NewScoringRule(Scoring_TGM)
+	gs.Rules.LockReset = LockReset_Step
+}
+
+func (MasterMode) Spawned(gs *GameState) {
+	// Stop before the end of each section, only clearing lines moves on to the next one
+	if gs.masterLevel%100 == 99 || gs.masterLevel == MasterMaxLevel-1 {
+		return
+	}
+
+	gs.masterLevel++
+}
+
+func (MasterMode) Completed(gs *GameState, lines int) {
+	gs.masterLevel = min(gs.masterLevel+lines, MasterMaxLevel)
+}
+
+func (MasterMode) ToppedOut(gs *GameState) bool {
+	return true
+}
+
+// SectionEnd returns the level the current section stops at
+func (MasterMode) SectionEnd(gs *GameState) int {
+	return min(gs.masterLevel/100*100+100, MasterMaxLevel)
+}
+
+// masterGrades are the points needed for each grade, from the lowest
+var masterGrades = [...]struct {
+	name   string
+	points int
+}{
+	{"9", 0}, {"8", 400}, {"7", 800}, {"6", 1400}, {"5", 2000}, {"4", 3500}, {"3", 5500}, {"2", 8000}, {"1", 12000},
+	{"S1", 16000}, {"S2", 22000}, {"S3", 30000}, {"S4", 40000}, {"S5", 52000},
+	{"S6", 66000}, {"S7", 82000}, {"S8", 100000}, {"S9", 120000},
+}
+
+const (
+	// masterGMPoints and masterGMTime are what's needed at level 999 for the GM grade
+	masterGMPoints int           = 126000
+	masterGMTime   time.Duration = time.Minute*13 + time.Second*30
+)
+
+// Grade returns the grade earned so far, from 9 up to S9, or GM for finishing fast with enough points
+func (m MasterMode) Grade(gs *GameState) string {
+	if m.IsFinished(gs) && gs.Score >= masterGMPoints && gs.Time() <= masterGMTime {
+		return "GM"
+	}
+
+	grade := masterGrades[0].name
+	for _, g := range masterGrades {
+		if gs.Score >= g.points {
+			grade = g.name
+		}
+	}
+
+	return grade
+}
@@ -0,0 +1,378 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+)
+
+// Mode decides how levels progress, and when a game is won.
+// Modes hold no state of their own, the same mode can start many games.
+type Mode interface {
+	// Name identifies the kind of mode, in replays and records
+	Name() string
+	// Level returns the current level, which sets gravity and multiplies points
+	Level(gs *GameState) int
+	// IsFinished returns true once the goal of the mode is reached
+	IsFinished(gs *GameState) bool
+
+	// Timing returns how fast the game runs, it's called every frame
+	Timing(gs *GameState) Timing
+
+	// Start is called once, before the first tetromino spawns
+	Start(gs *GameState)
+	// Spawned is called every time a tetromino spawns from the queue
+	Spawned(gs *GameState)
+	// Completed is called after every lock, once full rows are deleted
+	Completed(gs *GameState, lines int)
+	// ToppedOut is called when a tetromino spawns overlapping the stack.
+	// Returns true to end the game, or false if the mode made room for it.
+	ToppedOut(gs *GameState) bool
+}
+
+//// Marathon
+
+// MarathonMode levels up as lines are cleared, until EndLevel is cleared
+type MarathonMode struct {
+	StartLevel int
+	// EndLevel is the last level, the game is finished once it's cleared.
+	// 0 is endless, the level stops going up at MarathonMaxLevel.
+	EndLevel int
+	Goal     MarathonGoalKind
+}
+
+// MarathonMaxLevel is the highest level, gravity stops getting faster here
+const MarathonMaxLevel int = 20
+
+// DefaultMarathonMode follows the Tetris Guideline, 15 levels of 10 lines each
+func DefaultMarathonMode() MarathonMode {
+	return MarathonMode{
+		StartLevel: 1,
+		EndLevel:   15,
+		Goal:       MarathonGoal_Fixed,
+	}
+}
+
+type MarathonGoalKind int
+
+const (
+	// Fixed: Every level is 10 lines.
+	MarathonGoal_Fixed MarathonGoalKind = iota
+	// Variable: Every level is 5 lines times the level.
+	MarathonGoal_Variable
+)
+
+var marathonGoalNames = [...]string{
+	MarathonGoal_Fixed:    "fixed",
+	MarathonGoal_Variable: "variable",
+}
+
+// MarathonGoalKinds lists every kind of marathon goal, in order
+var MarathonGoalKinds = [...]MarathonGoalKind{MarathonGoal_Fixed, MarathonGoal_Variable}
+
+func (k MarathonGoalKind) String() string {
+	if k < 0 || int(k) >= len(marathonGoalNames) {
+		return fmt.Sprintf("MarathonGoalKind(%d)", int(k))
+	}
+
+	return marathonGoalNames[k]
+}
+
+// ParseMarathonGoalKind is the inverse of MarathonGoalKind.String
+func ParseMarathonGoalKind(name string) (MarathonGoalKind, error) {
+	for k, n := range marathonGoalNames {
+		if n == name {
+			return MarathonGoalKind(k), nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown marathon goal %q", name)
+}
+
+func (k MarathonGoalKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+func (k *MarathonGoalKind) UnmarshalText(text []byte) (err error) {
+	*k, err = ParseMarathonGoalKind(string(text))
+	return err
+}
+
+// Check returns an error if the levels can't be played, like an end level below the start level.
+// A game that starts past its end level would be finished before it begins.
+func (m MarathonMode) Check() error {
+	switch {
+	case m.StartLevel < 1 || m.StartLevel > MarathonMaxLevel:
+		return fmt.Errorf("start level must be from 1 to %d", MarathonMaxLevel)
+	case m.EndLevel < 0 || m.EndLevel > MarathonMaxLevel:
+		return fmt.Errorf("end level must be from 0 (endless) to %d", MarathonMaxLevel)
+	case m.EndLevel > 0 && m.EndLevel < m.StartLevel:
+		return fmt.Errorf("end level %d is below the start level %d", m.EndLevel, m.StartLevel)
+	}
+
+	return nil
+}
+
+// levelGoal returns the number of lines needed to clear a level
+func (m MarathonMode) levelGoal(level int) int {
+	if m.Goal == MarathonGoal_Variable {
+		return 5 * max(level, 1)
+	}
+
+	return linesClearedPerLevel
+}
+
+// progress returns the level reached after clearing lines without a cap,
+// and how many more lines are needed to clear it
+func (m MarathonMode) progress(lines int) (level, linesLeft int) {
+	level = m.StartLevel
+	for lines >= m.levelGoal(level) {
+		lines -= m.levelGoal(level)
+		level++
+	}
+
+	return level, m.levelGoal(level) - lines
+}
+
+func (MarathonMode) Name() string {
+	return "marathon"
+}
+
+func (m MarathonMode) Level(gs *GameState) int {
+	level, _ := m.progress(gs.linesCleared)
+
+	maxLevel := MarathonMaxLevel
+	if m.EndLevel > 0 && m.EndLevel < maxLevel {
+		maxLevel = m.EndLevel
+	}
+	if level > maxLevel {
+		// Don't go past the cap, unless starting above it
+		level = max(maxLevel, m.StartLevel)
+	}
+
+	return level
+}
+
+func (m MarathonMode) IsFinished(gs *GameState) bool {
+	level, _ := m.progress(gs.linesCleared)
+	return m.EndLevel > 0 && level > m.EndLevel
+}
+
+func (MarathonMode) Timing(gs *GameState) Timing {
+	return gs.standardTiming()
+}
+
+func (MarathonMode) Start(gs *GameState)                {}
+func (MarathonMode) Spawned(gs *GameState)              {}
+func (MarathonMode) Completed(gs *GameState, lines int) {}
+func (MarathonMode) ToppedOut(gs *GameState) bool       { return true }
+
+// LinesLeft returns how many more lines are needed to level up
+func (m MarathonMode) LinesLeft(gs *GameState) int {
+	_, linesLeft := m.progress(gs.linesCleared)
+	return linesLeft
+}
+
+//// Sprint
+
+// SprintMode is a race to clear a number of lines, with fixed gravity
+type SprintMode struct {
+	Lines int
+}
+
+func (SprintMode) Name() string {
+	return "sprint"
+}
+
+func (SprintMode) Level(gs *GameState) int {
+	return 1
+}
+
+// Check returns an error if the sprint can't be finished
+func (m SprintMode) Check() error {
+	if m.Lines <= 0 {
+		return fmt.Errorf("lines must be more than 0")
+	}
+
+	return nil
+}
+
+func (m SprintMode) IsFinished(gs *GameState) bool {
+	return gs.linesCleared >= m.Lines
+}
+
+func (SprintMode) Timing(gs *GameState) Timing {
+	return gs.standardTiming()
+}
+
+func (SprintMode) Start(gs *GameState)                {}
+func (SprintMode) Spawned(gs *GameState)              {}
+func (SprintMode) Completed(gs *GameState, lines int) {}
+func (SprintMode) ToppedOut(gs *GameState) bool       { return true }
+
+//// Ultra
+
+// UltraMode is a race to score as many points as possible before time runs out, with fixed gravity
+type UltraMode struct {
+	TimeLimit time.Duration
+}
+
+func (UltraMode) Name() string {
+	return "ultra"
+}
+
+func (UltraMode) Level(gs *GameState) int {
+	return 1
+}
+
+// Check returns an error if the ultra has no time to play
+func (m UltraMode) Check() error {
+	if m.TimeLimit <= 0 {
+		return fmt.Errorf("time limit must be more than 0")
+	}
+
+	return nil
+}
+
+func (m UltraMode) IsFinished(gs *GameState) bool {
+	return gs.frames >= Frames(m.TimeLimit)
+}
+
+func (UltraMode) Timing(gs *GameState) Timing {
+	return gs.standardTiming()
+}
+
+func (UltraMode) Start(gs *GameState)                {}
+func (UltraMode) Spawned(gs *GameState)              {}
+func (UltraMode) Completed(gs *GameState, lines int) {}
+func (UltraMode) ToppedOut(gs *GameState) bool       { return true }
+
+// TimeLeft returns how long is left before the game ends
+func (m UltraMode) TimeLeft(gs *GameState) time.Duration {
+	if left := m.TimeLimit - gs.Time(); left > 0 {
+		return left
+	}
+
+	return 0
+}
+
+//// Dig
+
+// DigMode is a race to clear rows of garbage, with fixed gravity.
+// The board starts with some garbage, and more is added as it's cleared.
+type DigMode struct {
+	// Rows is the number of garbage rows to clear
+	Rows int
+	// Height is the number of garbage rows kept on the board, until all of them have been added
+	Height int
+	// Messiness is the chance, from 0 to 1, of the hole moving between rows
+	Messiness float64
+}
+
+// DefaultDigMode is a race to clear 18 rows, 10 at a time, with the hole moving every few rows
+func DefaultDigMode() DigMode {
+	return DigMode{
+		Rows:      18,
+		Height:    10,
+		Messiness: 0.3,
+	}
+}
+
+func (DigMode) Name() string {
+	return "dig"
+}
+
+func (DigMode) Level(gs *GameState) int {
+	return 1
+}
+
+// Check returns an error if the garbage can't be dug, like a height taller than the visible board
+func (m DigMode) Check() error {
+	switch {
+	case m.Rows <= 0:
+		return fmt.Errorf("rows must be more than 0")
+	case m.Height <= 0 || m.Height > int(BoardCellsY_Visible):
+		return fmt.Errorf("height must be from 1 to %d", BoardCellsY_Visible)
+	case m.Messiness < 0 || m.Messiness > 1:
+		return fmt.Errorf("messiness must be from 0 to 1")
+	}
+
+	return nil
+}
+
+func (m DigMode) IsFinished(gs *GameState) bool {
+	return gs.garbageAdded >= m.Rows && gs.garbageRowsLeft() == 0
+}
+
+func (DigMode) Timing(gs *GameState) Timing {
+	return gs.standardTiming()
+}
+
+func (m DigMode) Start(gs *GameState) {
+	gs.AddGarbage(min(m.Height, m.Rows), m.Messiness)
+}
+
+func (DigMode) Spawned(gs *GameState) {}
+
+func (m DigMode) Completed(gs *GameState, lines int) {
+	// Fill back up to the height, with the rows that are left
+	rows := min(m.Height-gs.garbageRowsLeft(), m.Rows-gs.garbageAdded)
+	if rows > 0 {
+		gs.AddGarbage(rows, m.Messiness)
+	}
+}
+
+func (DigMode) ToppedOut(gs *GameState) bool {
+	return true
+}
+
+// GarbageLeft returns how many garbage rows are left to clear, including the ones not added yet
+func (m DigMode) GarbageLeft(gs *GameState) int {
+	return max(m.Rows-gs.garbageAdded, 0) + gs.garbageRowsLeft()
+}
+
+//// Zen
+
+// ZenMode never ends. Rows in the way of a new tetromino are cleared instead of topping out,
+// and every placement can be undone.
+type ZenMode struct {
+	// Gravity is false to leave tetrominos where they are until they're dropped
+	Gravity bool
+}
+
+func (ZenMode) Name() string {
+	return "zen"
+}
+
+func (ZenMode) Level(gs *GameState) int {
+	return 1
+}
+
+func (ZenMode) IsFinished(gs *GameState) bool {
+	return false
+}
+
+func (m ZenMode) Timing(gs *GameState) Timing {
+	t := gs.standardTiming()
+	if !m.Gravity {
+		t.Gravity = Gravity{Cells: 0, Frames: 1}
+	}
+
+	return t
+}
+
+func (ZenMode) Start(gs *GameState) {}
+
+func (ZenMode) Spawned(gs *GameState) {
+	gs.pushUndo()
+}
+
+func (ZenMode) Completed(gs *GameState, lines int) {}
+
+// ToppedOut clears the highest rows of the stack, until the tetromino fits
+func (ZenMode) ToppedOut(gs *GameState) bool {
+	for y := BoardCellsY - 1; y >= 0 && gs.ActiveTetromino.CheckCollision(&gs.Board); y-- {
+		gs.Board[y] = [BoardCellsX]Cell{}
+	}
+
+	return false
+}
@@ -0,0 +1,220 @@
+package engine
+
+import (
+	"fmt"
+)
+
+// Randomizer decides which kind of tetromino comes next
+type Randomizer interface {
+	Next() Kind
+	// Clone returns a copy that deals the same kinds from here on, without affecting this one
+	Clone() Randomizer
+}
+
+type RandomizerKind int
+
+const (
+	// Bag7: Deal all seven kinds in a random order, then start again.
+	Randomizer_Bag7 RandomizerKind = iota
+	// Bag14: Like Bag7, with two of each kind in the bag.
+	Randomizer_Bag14
+	// Classic: Every kind is equally likely, every time.
+	Randomizer_Classic
+	// NES: Reroll once if the kind is the same as the last one.
+	Randomizer_NES
+	// TGM: Reroll up to 4 times if the kind is in the last 4 dealt.
+	Randomizer_TGM
+)
+
+var randomizerNames = [...]string{
+	Randomizer_Bag7:    "7-bag",
+	Randomizer_Bag14:   "14-bag",
+	Randomizer_Classic: "classic",
+	Randomizer_NES:     "nes",
+	Randomizer_TGM:     "tgm",
+}
+
+// RandomizerKinds lists every kind of randomizer, in order
+var RandomizerKinds = [...]RandomizerKind{Randomizer_Bag7, Randomizer_Bag14, Randomizer_Classic, Randomizer_NES, Randomizer_TGM}
+
+func (k RandomizerKind) String() string {
+	if k < 0 || int(k) >= len(randomizerNames) {
+		return fmt.Sprintf("RandomizerKind(%d)", int(k))
+	}
+
+	return randomizerNames[k]
+}
+
+// ParseRandomizerKind is the inverse of RandomizerKind.String
+func ParseRandomizerKind(name string) (RandomizerKind, error) {
+	for k, n := range randomizerNames {
+		if n == name {
+			return RandomizerKind(k), nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown randomizer %q", name)
+}
+
+func (k RandomizerKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+func (k *RandomizerKind) UnmarshalText(text []byte) (err error) {
+	*k, err = ParseRandomizerKind(string(text))
+	return err
+}
+
+// NewRandomizer creates a randomizer of the given kind.
+// Randomizers with the same kind and seed deal the same kinds, on every machine.
+func NewRandomizer(kind RandomizerKind, seed int64) Randomizer {
+	r := rng{state: uint64(seed)}
+
+	switch kind {
+	case Randomizer_Bag7:
+		return &bagRandomizer{rng: r, copies: 1}
+	case Randomizer_Bag14:
+		return &bagRandomizer{rng: r, copies: 2}
+	case Randomizer_Classic:
+		return &classicRandomizer{rng: r}
+	case Randomizer_NES:
+		return &nesRandomizer{rng: r}
+	case Randomizer_TGM:
+		return &tgmRandomizer{
+			rng:     r,
+			history: [4]Kind{Kind_Z, Kind_Z, Kind_Z, Kind_Z},
+			isFirst: true,
+		}
+	}
+
+	panic(fmt.Sprintf("NewRandomizer: invalid kind %v", kind))
+}
+
+// rng is a small splitmix64 generator.
+// Unlike math/rand, it's a plain value, so randomizers can be copied.
+type rng struct {
+	state uint64
+}
+
+func (r *rng) next() uint64 {
+	r.state += 0x9E3779B97F4A7C15
+	z := r.state
+	z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+	z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+	return z ^ (z >> 31)
+}
+
+// intn returns a number in [0, n)
+func (r *rng) intn(n int) int {
+	return int(r.next() % uint64(n))
+}
+
+// float64 returns a number in [0, 1)
+func (r *rng) float64() float64 {
+	return float64(r.next()>>11) / (1 << 53)
+}
+
+type bagRandomizer struct {
+	rng    rng
+	copies int
+	bag    []Kind
+}
+
+func (b *bagRandomizer) Next() Kind {
+	if len(b.bag) == 0 {
+		// Refill and shuffle the bag
+		for i := 0; i < b.copies; i++ {
+			b.bag = append(b.bag, Kinds[:]...)
+		}
+		for i := len(b.bag) - 1; i > 0; i-- {
+			j := b.rng.intn(i + 1)
+			b.bag[i], b.bag[j] = b.bag[j], b.bag[i]
+		}
+	}
+
+	kind := b.bag[0]
+	b.bag = b.bag[1:]
+	return kind
+}
+
+func (b *bagRandomizer) Clone() Randomizer {
+	clone := *b
+	clone.bag = append([]Kind(nil), b.bag...)
+	return &clone
+}
+
+type classicRandomizer struct {
+	rng rng
+}
+
+func (c *classicRandomizer) Next() Kind {
+	return Kinds[c.rng.intn(len(Kinds))]
+}
+
+func (c *classicRandomizer) Clone() Randomizer {
+	clone := *c
+	return &clone
+}
+
+type nesRandomizer struct {
+	rng  rng
+	last Kind
+}
+
+func (n *nesRandomizer) Next() Kind {
+	// Roll an eighth "kind", which also means reroll
+	roll := n.rng.intn(len(Kinds) + 1)
+	if roll == len(Kinds) || Kinds[roll] == n.last {
+		roll = n.rng.intn(len(Kinds))
+	}
+
+	n.last = Kinds[roll]
+	return n.last
+}
+
+func (n *nesRandomizer) Clone() Randomizer {
+	clone := *n
+	return &clone
+}
+
+type tgmRandomizer struct {
+	rng     rng
+	history [4]Kind
+	isFirst bool
+}
+
+func (t *tgmRandomizer) Next() Kind {
+	var kind Kind
+	if t.isFirst {
+		// The first tetromino is never an S, Z or O
+		firsts := [4]Kind{Kind_I, Kind_T, Kind_L, Kind_J}
+		kind = firsts[t.rng.intn(len(firsts))]
+		t.isFirst = false
+	} else {
+		for try := 0; try < 4; try++ {
+			kind = Kinds[t.rng.intn(len(Kinds))]
+			if !t.inHistory(kind) {
+				break
+			}
+		}
+	}
+
+	copy(t.history[1:], t.history[:len(t.history)-1])
+	t.history[0] = kind
+	return kind
+}
+
+func (t *tgmRandomizer) Clone() Randomizer {
+	clone := *t
+	return &clone
+}
+
+func (t *tgmRandomizer) inHistory(kind Kind) bool {
+	for _, k := range t.history {
+		if k == kind {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,101 @@
+package engine
+
+import (
+	"testing"
+)
+
+// deal returns the next n kinds from r, as their names
+func deal(r Randomizer, n int) string {
+	s := ""
+	for i := 0; i < n; i++ {
+		s += r.Next().String()
+	}
+
+	return s
+}
+
+// TestRandomizerSequences pins what each randomizer deals for a seed.
+// Replays and online matches rely on these never changing, on any machine.
+func TestRandomizerSequences(t *testing.T) {
+	tests := []struct {
+		kind RandomizerKind
+		seed int64
+		want string
+	}{
+		{kind: Randomizer_Bag7, seed: 42, want: "TJZOLISIJLSOZT"},
+		{kind: Randomizer_Bag14, seed: 42, want: "TTOLJLIZJOSZIS"},
+		{kind: Randomizer_Classic, seed: 42, want: "SSOTZJTZZSSZIJ"},
+		{kind: Randomizer_NES, seed: 42, want: "SLTJTZSJSZZJSJ"},
+		{kind: Randomizer_TGM, seed: 42, want: "TSOJZZIOSLJZJS"},
+	}
+
+	for _, tt := range tests {
+		if got := deal(NewRandomizer(tt.kind, tt.seed), len(tt.want)); got != tt.want {
+			t.Errorf("%v with seed %d dealt %s, want %s", tt.kind, tt.seed, got, tt.want)
+		}
+	}
+}
+
+func TestRandomizerDeterminism(t *testing.T) {
+	for _, kind := range RandomizerKinds {
+		for seed := int64(-2); seed < 50; seed++ {
+			a := deal(NewRandomizer(kind, seed), 100)
+			b := deal(NewRandomizer(kind, seed), 100)
+			if a != b {
+				t.Fatalf("%v with seed %d dealt %s, then %s", kind, seed, a, b)
+			}
+		}
+
+		if deal(NewRandomizer(kind, 1), 100) == deal(NewRandomizer(kind, 2), 100) {
+			t.Errorf("%v dealt the same with seeds 1 and 2", kind)
+		}
+	}
+}
+
+func TestRandomizerClone(t *testing.T) {
+	for _, kind := range RandomizerKinds {
+		r := NewRandomizer(kind, 7)
+		// Clone part way through a bag
+		deal(r, 3)
+
+		clone := r.Clone()
+		want := deal(r, 50)
+		if got := deal(clone, 50); got != want {
+			t.Errorf("%v clone dealt %s, want %s", kind, got, want)
+		}
+	}
+}
+
+func TestBagRandomizers(t *testing.T) {
+	tests := []struct {
+		kind   RandomizerKind
+		copies int
+	}{
+		{kind: Randomizer_Bag7, copies: 1},
+		{kind: Randomizer_Bag14, copies: 2},
+	}
+
+	for _, tt := range tests {
+		r := NewRandomizer(tt.kind, 3)
+		for bag := 0; bag < 20; bag++ {
+			counts := map[Kind]int{}
+			for i := 0; i < len(Kinds)*tt.copies; i++ {
+				counts[r.Next()]++
+			}
+			for _, k := range Kinds {
+				if counts[k] != tt.copies {
+					t.Fatalf("%v bag %d dealt %d %v, want %d", tt.kind, bag, counts[k], k, tt.copies)
+				}
+			}
+		}
+	}
+}
+
+func TestTGMRandomizerFirst(t *testing.T) {
+	for seed := int64(0); seed < 200; seed++ {
+		switch first := NewRandomizer(Randomizer_TGM, seed).Next(); first {
+		case Kind_S, Kind_Z, Kind_O:
+			t.Fatalf("seed %d dealt %v first", seed, first)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package engine
+
+import (
+	"fmt"
+)
+
+// RotationSystem decides how tetrominos spawn, and how they rotate
+type RotationSystem interface {
+	// Spawn creates a tetromino of the given kind, in its spawn position and orientation
+	Spawn(kind Kind) *Tetromino
+	// Rotate tries to rotate the tetromino in the given direction.
+	// Returns which kick moved it into place, 0 if it didn't need to move.
+	// Returns false, leaving the tetromino untouched, if it couldn't be rotated.
+	Rotate(t *Tetromino, b *Board, clockwise bool) (kick int, ok bool)
+}
+
+type RotationSystemKind int
+
+const (
+	// SRS: The Super Rotation System from the Tetris Guideline, with wall kicks.
+	RotationSystem_SRS RotationSystemKind = iota
+	// Classic: Turn the cells around the origin, give up if they don't fit.
+	RotationSystem_Classic
+)
+
+var rotationSystemNames = [...]string{
+	RotationSystem_SRS:     "srs",
+	RotationSystem_Classic: "classic",
+}
+
+// RotationSystemKinds lists every kind of rotation system, in order
+var RotationSystemKinds = [...]RotationSystemKind{RotationSystem_SRS, RotationSystem_Classic}
+
+func (k RotationSystemKind) String() string {
+	if k < 0 || int(k) >= len(rotationSystemNames) {
+		return fmt.Sprintf("RotationSystemKind(%d)", int(k))
+	}
+
+	return rotationSystemNames[k]
+}
+
+// ParseRotationSystemKind is the inverse of RotationSystemKind.String
+func ParseRotationSystemKind(name string) (RotationSystemKind, error) {
+	for k, n := range rotationSystemNames {
+		if n == name {
+			return RotationSystemKind(k), nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown rotation system %q", name)
+}
+
+func (k RotationSystemKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+func (k *RotationSystemKind) UnmarshalText(text []byte) (err error) {
+	*k, err = ParseRotationSystemKind(string(text))
+	return err
+}
+
+func NewRotationSystem(kind RotationSystemKind) RotationSystem {
+	switch kind {
+	case RotationSystem_SRS:
+		return srsRotation{}
+	case RotationSystem_Classic:
+		return classicRotation{}
+	}
+
+	panic(fmt.Sprintf("NewRotationSystem: invalid kind %v", kind))
+}
+
+//// Classic
+
+type classicRotation struct{}
+
+func (classicRotation) Spawn(kind Kind) *Tetromino {
+	return newClassicTetromino(kind, TetrominoGenerateX, TetrominoGenerateY)
+}
+
+func (classicRotation) Rotate(t *Tetromino, b *Board, clockwise bool) (int, bool) {
+	rotated := *t
+	if clockwise {
+		rotated.RotateClockwise()
+	} else {
+		rotated.RotateCounterClockwise()
+	}
+
+	if rotated.CheckCollision(b) {
+		return 0, false
+	}
+
+	*t = rotated
+	return 0, true
+}
+
+//// SRS
+// https://tetris.wiki/Super_Rotation_System
+
+// srsShapes holds the cells of each kind, in each orientation
+var srsShapes [Kind_Z + 1][4][4][2]int32
+
+// srsKicks are the offsets tried in order when rotating, indexed by the starting orientation.
+// The first list is for clockwise rotation, the second for counter clockwise.
+type srsKickTable [4][2][5][2]int32
+
+var srsKicksJLSTZ = srsKickTable{
+	Rotation_Spawn: {
+		{{0, 0}, {-1, 0}, {-1, 1}, {0, -2}, {-1, -2}}, // 0->R
+		{{0, 0}, {1, 0}, {1, 1}, {0, -2}, {1, -2}},    // 0->L
+	},
+	Rotation_Right: {
+		{{0, 0}, {1, 0}, {1, -1}, {0, 2}, {1, 2}}, // R->2
+		{{0, 0}, {1, 0}, {1, -1}, {0, 2}, {1, 2}}, // R->0
+	},
+	Rotation_Flipped: {
+		{{0, 0}, {1, 0}, {1, 1}, {0, -2}, {1, -2}},    // 2->L
+		{{0, 0}, {-1, 0}, {-1, 1}, {0, -2}, {-1, -2}}, // 2->R
+	},
+	Rotation_Left: {
+		{{0, 0}, {-1, 0}, {-1, -1}, {0, 2}, {-1, 2}}, // L->0
+		{{0, 0}, {-1, 0}, {-1, -1}, {0, 2}, {-1, 2}}, // L->2
+	},
+}
+
+var srsKicksI = srsKickTable{
+	Rotation_Spawn: {
+		{{0, 0}, {-2, 0}, {1, 0}, {-2, -1}, {1, 2}}, // 0->R
+		{{0, 0}, {-1, 0}, {2, 0}, {-1, 2}, {2, -1}}, // 0->L
+	},
+	Rotation_Right: {
+		{{0, 0}, {-1, 0}, {2, 0}, {-1, 2}, {2, -1}}, // R->2
+		{{0, 0}, {2, 0}, {-1, 0}, {2, 1}, {-1, -2}}, // R->0
+	},
+	Rotation_Flipped: {
+		{{0, 0}, {2, 0}, {-1, 0}, {2, 1}, {-1, -2}}, // 2->L
+		{{0, 0}, {1, 0}, {-2, 0}, {1, -2}, {-2, 1}}, // 2->R
+	},
+	Rotation_Left: {
+		{{0, 0}, {1, 0}, {-2, 0}, {1, -2}, {-2, 1}}, // L->0
+		{{0, 0}, {-2, 0}, {1, 0}, {-2, -1}, {1, 2}}, // L->2
+	},
+}
+
+func init() {
+	// Spawn orientations, the origin is the center of rotation.
+	// The I-tetromino rotates around the corner of 4 cells, down and to the right of its origin.
+	spawns := map[Kind][4][2]int32{
+		Kind_O: {{0, 0}, {1, 0}, {0, 1}, {1, 1}},
+		Kind_I: {{-1, 0}, {0, 0}, {1, 0}, {2, 0}},
+		Kind_T: {{-1, 0}, {0, 0}, {1, 0}, {0, 1}},
+		Kind_L: {{-1, 0}, {0, 0}, {1, 0}, {1, 1}},
+		Kind_J: {{-1, 1}, {-1, 0}, {0, 0}, {1, 0}},
+		Kind_S: {{-1, 0}, {0, 0}, {0, 1}, {1, 1}},
+		Kind_Z: {{-1, 1}, {0, 1}, {0, 0}, {1, 0}},
+	}
+
+	for kind, cells := range spawns {
+		for r := Rotation_Spawn; r <= Rotation_Left; r++ {
+			srsShapes[kind][r] = cells
+
+			for i, cell := range cells {
+				switch kind {
+				case Kind_O:
+					// The O-tetromino doesn't change when rotated
+				case Kind_I:
+					// Rotate around (0.5, -0.5) in doubled coordinates, to stay in integers
+					x, y := 2*cell[0]-1, 2*cell[1]+1
+					x, y = y, -x
+					cells[i] = [2]int32{(x + 1) / 2, (y - 1) / 2}
+				default:
+					cells[i] = [2]int32{cell[1], -cell[0]}
+				}
+			}
+		}
+	}
+}
+
+type srsRotation struct{}
+
+func (srsRotation) Spawn(kind Kind) *Tetromino {
+	// Spawn in the left-center, so 3 wide tetrominos are centered
+	return &Tetromino{
+		OriginX:  TetrominoGenerateX - 1,
+		OriginY:  TetrominoGenerateY,
+		Cells:    srsShapes[kind][Rotation_Spawn],
+		Kind:     kind,
+		Rotation: Rotation_Spawn,
+	}
+}
+
+func (srsRotation) Rotate(t *Tetromino, b *Board, clockwise bool) (int, bool) {
+	direction := 0
+	if !clockwise {
+		direction = 1
+	}
+
+	kicks := &srsKicksJLSTZ
+	if t.Kind == Kind_I {
+		kicks = &srsKicksI
+	}
+
+	rotated := *t
+	rotated.Rotation = t.Rotation.Rotated(clockwise)
+	rotated.Cells = srsShapes[t.Kind][rotated.Rotation]
+
+	// Try each kick until one fits
+	for i, kick := range kicks[t.Rotation][direction] {
+		rotated.OriginX = t.OriginX + kick[0]
+		rotated.OriginY = t.OriginY + kick[1]
+
+		if !rotated.CheckCollision(b) {
+			*t = rotated
+			return i, true
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,169 @@
+package engine
+
+import (
+	"testing"
+)
+
+// boardExcept returns a full board, with only the given cells empty
+func boardExcept(cells ...[2]int32) *Board {
+	b := &Board{}
+	for y := range b {
+		for x := range b[y] {
+			b[y][x].IsFilled = true
+		}
+	}
+	for _, c := range cells {
+		b[c[1]][c[0]].IsFilled = false
+	}
+
+	return b
+}
+
+// absoluteCells returns where the cells of t are on the board
+func absoluteCells(t *Tetromino) [][2]int32 {
+	var cells [][2]int32
+	t.cellIterator(func(x, y int32) bool {
+		cells = append(cells, [2]int32{x, y})
+		return false
+	})
+
+	return cells
+}
+
+func TestSRSShapes(t *testing.T) {
+	// From the guideline, with y going up
+	tests := []struct {
+		kind     Kind
+		rotation Rotation
+		want     [4][2]int32
+	}{
+		{Kind_T, Rotation_Spawn, [4][2]int32{{-1, 0}, {0, 0}, {1, 0}, {0, 1}}},
+		{Kind_T, Rotation_Right, [4][2]int32{{0, 1}, {0, 0}, {0, -1}, {1, 0}}},
+		{Kind_T, Rotation_Flipped, [4][2]int32{{1, 0}, {0, 0}, {-1, 0}, {0, -1}}},
+		{Kind_T, Rotation_Left, [4][2]int32{{0, -1}, {0, 0}, {0, 1}, {-1, 0}}},
+		{Kind_I, Rotation_Spawn, [4][2]int32{{-1, 0}, {0, 0}, {1, 0}, {2, 0}}},
+		{Kind_I, Rotation_Right, [4][2]int32{{1, 1}, {1, 0}, {1, -1}, {1, -2}}},
+		{Kind_I, Rotation_Flipped, [4][2]int32{{2, -1}, {1, -1}, {0, -1}, {-1, -1}}},
+		{Kind_I, Rotation_Left, [4][2]int32{{0, -2}, {0, -1}, {0, 0}, {0, 1}}},
+		{Kind_O, Rotation_Flipped, [4][2]int32{{0, 0}, {1, 0}, {0, 1}, {1, 1}}},
+	}
+
+	for _, tt := range tests {
+		if got := srsShapes[tt.kind][tt.rotation]; got != tt.want {
+			t.Errorf("%v in rotation %d = %v, want %v", tt.kind, tt.rotation, got, tt.want)
+		}
+	}
+}
+
+// TestSRSKicks rotates with only the cells of the expected kick open, so every kick before it collides
+func TestSRSKicks(t *testing.T) {
+	tests := []struct {
+		name      string
+		kind      Kind
+		from      Rotation
+		clockwise bool
+		wantKick  int
+		// offset is where the kick moves the origin, from the guideline's tables
+		offset [2]int32
+	}{
+		{"T 0->R", Kind_T, Rotation_Spawn, true, 3, [2]int32{0, -2}},
+		{"T L->0", Kind_T, Rotation_Left, true, 4, [2]int32{-1, 2}},
+		{"T 0->L", Kind_T, Rotation_Spawn, false, 1, [2]int32{1, 0}},
+		{"J 2->R", Kind_J, Rotation_Flipped, false, 2, [2]int32{-1, 1}},
+		{"L R->2", Kind_L, Rotation_Right, true, 4, [2]int32{1, 2}},
+		{"S R->0", Kind_S, Rotation_Right, false, 1, [2]int32{1, 0}},
+		{"Z 2->L", Kind_Z, Rotation_Flipped, true, 3, [2]int32{0, -2}},
+		{"I 0->R", Kind_I, Rotation_Spawn, true, 4, [2]int32{1, 2}},
+		{"I R->0", Kind_I, Rotation_Right, false, 3, [2]int32{2, 1}},
+		{"I L->2", Kind_I, Rotation_Left, false, 1, [2]int32{-2, 0}},
+		{"I 2->R", Kind_I, Rotation_Flipped, false, 2, [2]int32{-2, 0}},
+		{"O", Kind_O, Rotation_Spawn, true, 0, [2]int32{0, 0}},
+	}
+
+	rs := NewRotationSystem(RotationSystem_SRS)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tetromino := &Tetromino{OriginX: 4, OriginY: 10, Kind: tt.kind, Rotation: tt.from, Cells: srsShapes[tt.kind][tt.from]}
+			to := tt.from.Rotated(tt.clockwise)
+			want := Tetromino{
+				OriginX:  tetromino.OriginX + tt.offset[0],
+				OriginY:  tetromino.OriginY + tt.offset[1],
+				Kind:     tt.kind,
+				Rotation: to,
+				Cells:    srsShapes[tt.kind][to],
+			}
+
+			kick, ok := rs.Rotate(tetromino, boardExcept(absoluteCells(&want)...), tt.clockwise)
+			if !ok {
+				t.Fatalf("couldn't rotate")
+			}
+			if kick != tt.wantKick {
+				t.Errorf("used kick %d, want %d", kick, tt.wantKick)
+			}
+			if *tetromino != want {
+				t.Errorf("rotated to %+v, want %+v", *tetromino, want)
+			}
+		})
+	}
+}
+
+func TestSRSRotationBlocked(t *testing.T) {
+	rs := NewRotationSystem(RotationSystem_SRS)
+	tetromino := rs.Spawn(Kind_T)
+	before := *tetromino
+
+	// Only the tetromino's own cells are open, so no kick fits
+	if _, ok := rs.Rotate(tetromino, boardExcept(absoluteCells(tetromino)...), true); ok {
+		t.Errorf("rotated into a full board")
+	}
+	if *tetromino != before {
+		t.Errorf("a failed rotation moved the tetromino to %+v", *tetromino)
+	}
+}
+
+func TestRotateAllTheWayAround(t *testing.T) {
+	for _, system := range RotationSystemKinds {
+		rs := NewRotationSystem(system)
+		for _, kind := range Kinds {
+			for _, clockwise := range []bool{true, false} {
+				tetromino := rs.Spawn(kind)
+				spawned := *tetromino
+				for i := 0; i < 4; i++ {
+					kick, ok := rs.Rotate(tetromino, &Board{}, clockwise)
+					if !ok || kick != 0 {
+						t.Fatalf("%v %v: rotation %d used kick %d, ok %v on an empty board", system, kind, i, kick, ok)
+					}
+				}
+				if *tetromino != spawned {
+					t.Errorf("%v %v: 4 rotations ended at %+v, want %+v", system, kind, *tetromino, spawned)
+				}
+			}
+		}
+	}
+}
+
+func TestClassicRotationDoesntKick(t *testing.T) {
+	rs := NewRotationSystem(RotationSystem_Classic)
+
+	// A T pointing right, against the left wall, would poke into it when rotated clockwise
+	tetromino := rs.Spawn(Kind_T)
+	tetromino.RotateClockwise()
+	tetromino.OriginX = 0
+	before := *tetromino
+
+	if _, ok := rs.Rotate(tetromino, &Board{}, true); ok {
+		t.Errorf("rotated into the wall, to %+v", *tetromino)
+	}
+	if *tetromino != before {
+		t.Errorf("a failed rotation moved the tetromino to %+v", *tetromino)
+	}
+
+	// Away from the wall it turns in place
+	tetromino.OriginX = 4
+	if kick, ok := rs.Rotate(tetromino, &Board{}, true); !ok || kick != 0 {
+		t.Errorf("couldn't rotate in place, kick %d, ok %v", kick, ok)
+	}
+	if tetromino.OriginX != 4 || tetromino.OriginY != before.OriginY {
+		t.Errorf("rotating in place moved the origin to %d, %d", tetromino.OriginX, tetromino.OriginY)
+	}
+}
@@ -0,0 +1,127 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+)
+
+// Rules change how the game plays
+type Rules struct {
+	Randomizer     RandomizerKind
+	RotationSystem RotationSystemKind
+
+	// GenerationDelay is the number of frames before the next tetromino spawns
+	GenerationDelay int
+	// RowClearDelay is the number of frames cleared rows are shown before they're deleted
+	RowClearDelay int
+	// LockDelay is the number of frames a tetromino can rest on something before it locks
+	LockDelay int
+	// LockReset decides which actions restart the lock delay
+	LockReset LockResetKind
+	// LockResetLimit is how many times moving or rotating can restart the lock delay,
+	// until the tetromino falls to a new lowest row. Only used by LockReset_Move.
+	LockResetLimit int
+
+	Scoring ScoringKind
+
+	// Previews is how many of the upcoming tetrominos can be seen, up to TetrominoQueueSize
+	Previews int
+	// Hold allows the active tetromino to be swapped with the held one
+	Hold bool
+}
+
+// DefaultRules follow the Tetris Guideline
+func DefaultRules() Rules {
+	return Rules{
+		Randomizer:      Randomizer_Bag7,
+		RotationSystem:  RotationSystem_SRS,
+		GenerationDelay: Frames(time.Millisecond * 200),
+		RowClearDelay:   5, // ~0.083 seconds
+		LockDelay:       Frames(time.Millisecond * 500),
+		LockReset:       LockReset_Move,
+		LockResetLimit:  15,
+		Scoring:         Scoring_Guideline,
+		Previews:        TetrominoQueueSize,
+		Hold:            true,
+	}
+}
+
+// Check returns an error if a game can't be played with the rules, like a negative delay
+func (r Rules) Check() error {
+	switch {
+	case r.Randomizer < 0 || int(r.Randomizer) >= len(RandomizerKinds):
+		return fmt.Errorf("unknown randomizer %v", r.Randomizer)
+	case r.RotationSystem < 0 || int(r.RotationSystem) >= len(RotationSystemKinds):
+		return fmt.Errorf("unknown rotation system %v", r.RotationSystem)
+	case r.LockReset < 0 || int(r.LockReset) >= len(LockResetKinds):
+		return fmt.Errorf("unknown lock reset %v", r.LockReset)
+	case r.Scoring < 0 || int(r.Scoring) >= len(ScoringKinds):
+		return fmt.Errorf("unknown scoring %v", r.Scoring)
+	case r.GenerationDelay < 0 || r.RowClearDelay < 0 || r.LockDelay < 0:
+		return fmt.Errorf("delays can't be negative")
+	case r.LockResetLimit < 0:
+		return fmt.Errorf("lock resets can't be negative")
+	case r.Previews < 0 || r.Previews > TetrominoQueueSize:
+		return fmt.Errorf("previews must be from 0 to %d", TetrominoQueueSize)
+	}
+
+	return nil
+}
+
+// Frames converts a duration to the number of frames it lasts, to the nearest frame
+func Frames(d time.Duration) int {
+	return int((d + FrameDuration/2) / FrameDuration)
+}
+
+// Duration is the inverse of Frames, it returns how long a number of frames lasts
+func Duration(frames int) time.Duration {
+	return time.Duration(frames) * time.Second / time.Duration(FramesPerSecond)
+}
+
+type LockResetKind int
+
+const (
+	// Move: Moving or rotating restarts the lock delay, up to LockResetLimit times.
+	LockReset_Move LockResetKind = iota
+	// Infinite: Moving or rotating always restarts the lock delay.
+	LockReset_Infinite
+	// Step: Only falling to a new lowest row restarts the lock delay.
+	LockReset_Step
+)
+
+var lockResetNames = [...]string{
+	LockReset_Move:     "move",
+	LockReset_Infinite: "infinite",
+	LockReset_Step:     "step",
+}
+
+// LockResetKinds lists every kind of lock reset, in order
+var LockResetKinds = [...]LockResetKind{LockReset_Move, LockReset_Infinite, LockReset_Step}
+
+func (k LockResetKind) String() string {
+	if k < 0 || int(k) >= len(lockResetNames) {
+		return fmt.Sprintf("LockResetKind(%d)", int(k))
+	}
+
+	return lockResetNames[k]
+}
+
+// ParseLockResetKind is the inverse of LockResetKind.String
+func ParseLockResetKind(name string) (LockResetKind, error) {
+	for k, n := range lockResetNames {
+		if n == name {
+			return LockResetKind(k), nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown lock reset %q", name)
+}
+
+func (k LockResetKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+func (k *LockResetKind) UnmarshalText(text []byte) (err error) {
+	*k, err = ParseLockResetKind(string(text))
+	return err
+}
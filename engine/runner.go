@@ -0,0 +1,103 @@
+package engine
+
+import (
+	"time"
+)
+
+// TimedInputEvent is an input, with the time it happened on a Runner's clock
+type TimedInputEvent struct {
+	InputEvent
+	Time time.Duration
+}
+
+// InputQueue holds inputs until the step for the frame they happened in.
+// Pushing never blocks or drops inputs. It isn't safe to share between goroutines,
+// it belongs to whatever owns the game.
+type InputQueue struct {
+	events []TimedInputEvent
+}
+
+// Push adds inputs that happened at the given time, which must not be before the inputs already queued
+func (q *InputQueue) Push(at time.Duration, events ...InputEvent) {
+	for _, e := range events {
+		q.events = append(q.events, TimedInputEvent{InputEvent: e, Time: at})
+	}
+}
+
+// Pop removes the inputs that happened before until, and appends them to events in order
+func (q *InputQueue) Pop(until time.Duration, events []InputEvent) []InputEvent {
+	n := 0
+	for n < len(q.events) && q.events[n].Time < until {
+		events = append(events, q.events[n].InputEvent)
+		n++
+	}
+
+	q.events = q.events[:copy(q.events, q.events[n:])]
+	return events
+}
+
+// Len returns how many inputs are queued
+func (q *InputQueue) Len() int {
+	return len(q.events)
+}
+
+// Stepper is stepped once every frame, with the inputs from that frame, like a GameState or a Versus match
+type Stepper interface {
+	Step(inputs []InputEvent)
+}
+
+// Runner owns a game, and is the only thing that steps it.
+// Inputs are queued with the time they happened, and the game is stepped once for every frame of time that passes,
+// with the inputs that happened during that frame.
+// Like the game, it isn't safe to share between goroutines: poll, advance and draw from the same one.
+type Runner struct {
+	Game Stepper
+
+	// MaxLag is how far the game can fall behind the clock, like after the window was dragged.
+	// Time it's behind by beyond that is skipped, instead of being caught up all at once. 0 never skips.
+	MaxLag time.Duration
+	// OnStep is called with the inputs of every step, before the game takes it
+	OnStep func(inputs []InputEvent)
+
+	queue InputQueue
+	// clock is the time passed, and stepped is the time the game has been stepped up to
+	clock   time.Duration
+	stepped time.Duration
+	inputs  []InputEvent
+}
+
+func NewRunner(game Stepper) *Runner {
+	return &Runner{Game: game}
+}
+
+// Now returns the time passed on the runner's clock
+func (r *Runner) Now() time.Duration {
+	return r.clock
+}
+
+// Push queues inputs as happening now
+func (r *Runner) Push(events ...InputEvent) {
+	r.queue.Push(r.clock, events...)
+}
+
+// Advance moves the clock on by elapsed, then steps the game for every whole frame that has passed.
+// Returns the number of steps taken.
+func (r *Runner) Advance(elapsed time.Duration) int {
+	r.clock += elapsed
+	if r.MaxLag > 0 && r.clock-r.stepped > r.MaxLag {
+		r.stepped = r.clock - r.MaxLag
+	}
+
+	steps := 0
+	for r.clock-r.stepped >= FrameDuration {
+		r.stepped += FrameDuration
+		r.inputs = r.queue.Pop(r.stepped, r.inputs[:0])
+		if r.OnStep != nil {
+			r.OnStep(r.inputs)
+		}
+		r.Game.Step(r.inputs)
+		steps++
+	}
+
+	return steps
+}
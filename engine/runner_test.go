@@ -0,0 +1,227 @@
+package engine
+
+import (
+	"math/rand"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder is a Stepper that keeps the inputs of every step
+type recorder struct {
+	steps [][]InputEvent
+}
+
+func (r *recorder) Step(inputs []InputEvent) {
+	r.steps = append(r.steps, append([]InputEvent{}, inputs...))
+}
+
+func press(input Input) InputEvent {
+	return InputEvent{Input: input, Action: Action_Down}
+}
+
+// syntheticInputs returns presses and releases of the inputs that play a game, without pausing or undoing
+func syntheticInputs(seed int64, count int) []InputEvent {
+	playing := []Input{
+		Input_Hold, Input_RotateCounterClockwise, Input_RotateClockwise, Input_HardDrop,
+		Input_SoftDrop, Input_MoveLeft, Input_MoveRight,
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	events := make([]InputEvent, 0, 2*count)
+	for i := 0; i < count; i++ {
+		input := playing[rng.Intn(len(playing))]
+		events = append(events, InputEvent{Input: input, Action: Action_Down}, InputEvent{Input: input, Action: Action_Up})
+	}
+
+	return events
+}
+
+func TestInputQueuePop(t *testing.T) {
+	var q InputQueue
+	q.Push(0, press(Input_MoveLeft))
+	q.Push(FrameDuration/2, press(Input_MoveRight), press(Input_HardDrop))
+	q.Push(FrameDuration, press(Input_Hold))
+
+	tests := []struct {
+		until time.Duration
+		want  []InputEvent
+		left  int
+	}{
+		{until: 0, want: nil, left: 4},
+		{until: FrameDuration / 2, want: []InputEvent{press(Input_MoveLeft)}, left: 3},
+		{until: FrameDuration, want: []InputEvent{press(Input_MoveRight), press(Input_HardDrop)}, left: 1},
+		{until: 3 * FrameDuration, want: []InputEvent{press(Input_Hold)}, left: 0},
+		{until: 4 * FrameDuration, want: nil, left: 0},
+	}
+	for _, tt := range tests {
+		got := q.Pop(tt.until, nil)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Pop(%v) = %v, want %v", tt.until, got, tt.want)
+		}
+		if q.Len() != tt.left {
+			t.Errorf("after Pop(%v), Len() = %d, want %d", tt.until, q.Len(), tt.left)
+		}
+	}
+}
+
+func TestRunnerStepsInputsInTheirFrame(t *testing.T) {
+	game := &recorder{}
+	r := NewRunner(game)
+	var onStep [][]InputEvent
+	r.OnStep = func(inputs []InputEvent) {
+		onStep = append(onStep, append([]InputEvent{}, inputs...))
+	}
+
+	// A frame and a half passes, with an input at the start and one in the middle of the second frame
+	r.Push(press(Input_MoveLeft))
+	if steps := r.Advance(FrameDuration * 3 / 2); steps != 1 {
+		t.Fatalf("Advance took %d steps, want 1", steps)
+	}
+	r.Push(press(Input_MoveRight))
+	if steps := r.Advance(FrameDuration / 2); steps != 1 {
+		t.Fatalf("Advance took %d steps, want 1", steps)
+	}
+	if steps := r.Advance(FrameDuration / 4); steps != 0 {
+		t.Fatalf("Advance took %d steps, want 0", steps)
+	}
+
+	want := [][]InputEvent{{press(Input_MoveLeft)}, {press(Input_MoveRight)}}
+	if !reflect.DeepEqual(game.steps, want) {
+		t.Errorf("stepped with %v, want %v", game.steps, want)
+	}
+	if !reflect.DeepEqual(onStep, want) {
+		t.Errorf("OnStep got %v, want %v", onStep, want)
+	}
+}
+
+func TestRunnerMaxLag(t *testing.T) {
+	game := &recorder{}
+	r := NewRunner(game)
+	r.MaxLag = 3 * FrameDuration
+
+	r.Push(press(Input_Hold))
+	if steps := r.Advance(time.Second); steps != 3 {
+		t.Errorf("Advance took %d steps, want 3", steps)
+	}
+	// Inputs from the skipped time are played in the first step that's taken
+	if len(game.steps) == 0 || !reflect.DeepEqual(game.steps[0], []InputEvent{press(Input_Hold)}) {
+		t.Errorf("first step had %v, want the hold", game.steps)
+	}
+	if r.Now() != time.Second {
+		t.Errorf("Now() = %v, want %v", r.Now(), time.Second)
+	}
+}
+
+// TestRunnerMatchesStepping checks that a game run with inputs pushed as they happen
+// plays out like the same game stepped frame by frame with the same inputs.
+func TestRunnerMatchesStepping(t *testing.T) {
+	const frames = 600
+	events := syntheticInputs(7, frames/4)
+
+	stepped := NewGameState(DefaultRules(), DefaultHandling(), MarathonMode{StartLevel: 1, EndLevel: 15}, 1)
+	ran := NewGameState(DefaultRules(), DefaultHandling(), MarathonMode{StartLevel: 1, EndLevel: 15}, 1)
+	r := NewRunner(ran)
+
+	for frame := 0; frame < frames; frame++ {
+		// Every other frame has an input, pushed in the middle of the frame
+		var inputs []InputEvent
+		if frame%2 == 0 && len(events) > 0 {
+			inputs = events[:1]
+			events = events[1:]
+		}
+
+		stepped.Step(inputs)
+		r.Advance(FrameDuration / 2)
+		r.Push(inputs...)
+		r.Advance(FrameDuration - FrameDuration/2)
+	}
+
+	if stepped.Frames() != ran.Frames() || stepped.Score != ran.Score || stepped.Board.Hash() != ran.Board.Hash() {
+		t.Errorf("ran to frame %d, score %d, board %016x; stepped to frame %d, score %d, board %016x",
+			ran.Frames(), ran.Score, ran.Board.Hash(), stepped.Frames(), stepped.Score, stepped.Board.Hash())
+	}
+}
+
+// TestRunnerConcurrentReader runs a game the way the window does: inputs arrive from another goroutine,
+// and what's drawn is copied out to a reader on yet another one. Run with -race.
+func TestRunnerConcurrentReader(t *testing.T) {
+	type snapshot struct {
+		frame int
+		score int
+		board Board
+	}
+
+	gs := NewGameState(DefaultRules(), DefaultHandling(), MarathonMode{StartLevel: 1, EndLevel: 15}, 3)
+	r := NewRunner(gs)
+
+	polled := make(chan InputEvent)
+	go func() {
+		for _, e := range syntheticInputs(3, 200) {
+			polled <- e
+		}
+		close(polled)
+	}()
+
+	snapshots := make(chan snapshot, 8)
+	var wg sync.WaitGroup
+	var read []snapshot
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for s := range snapshots {
+			read = append(read, s)
+		}
+	}()
+
+	steps := 0
+	for e := range polled {
+		r.Push(e)
+		steps += r.Advance(FrameDuration)
+		snapshots <- snapshot{frame: gs.Frames(), score: gs.Score, board: gs.Board}
+	}
+	close(snapshots)
+	wg.Wait()
+
+	if steps != 400 {
+		t.Errorf("took %d steps, want 400", steps)
+	}
+	if len(read) != 400 {
+		t.Fatalf("read %d snapshots, want 400", len(read))
+	}
+	for i := 1; i < len(read); i++ {
+		if read[i].frame < read[i-1].frame {
+			t.Fatalf("snapshot %d is at frame %d, before the last one at %d", i, read[i].frame, read[i-1].frame)
+		}
+	}
+	if last := read[len(read)-1]; last.frame != gs.Frames() || last.score != gs.Score || last.board != gs.Board {
+		t.Errorf("the last snapshot isn't the game as it ended")
+	}
+}
+
+// TestSameSeedSamePlay checks that games with the same seed, stepped with the same inputs, play out the same.
+// Replays and online matches only store inputs, and rely on this.
+func TestSameSeedSamePlay(t *testing.T) {
+	const frames = 3600
+	events := syntheticInputs(11, frames/2)
+
+	a := NewGameState(DefaultRules(), DefaultHandling(), MarathonMode{StartLevel: 1, EndLevel: 15}, 9)
+	b := NewGameState(DefaultRules(), DefaultHandling(), MarathonMode{StartLevel: 1, EndLevel: 15}, 9)
+	for frame := 0; frame < frames; frame++ {
+		inputs := events[frame : frame+1]
+		a.Step(inputs)
+		b.Step(inputs)
+
+		if a.Board.Hash() != b.Board.Hash() {
+			t.Fatalf("frame %d: boards %016x and %016x", frame, a.Board.Hash(), b.Board.Hash())
+		}
+	}
+
+	if a.Frames() != b.Frames() || a.Score != b.Score {
+		t.Errorf("ended at frames %d and %d, scores %d and %d", a.Frames(), b.Frames(), a.Score, b.Score)
+	}
+	if a.Board.Hash() == (&Board{}).Hash() {
+		t.Errorf("nothing was placed")
+	}
+}
@@ -0,0 +1,306 @@
+package engine
+
+import (
+	"fmt"
+)
+
+type TSpin int
+
+const (
+	TSpin_None TSpin = iota
+	TSpin_Mini
+	TSpin_Full
+)
+
+// Clear describes what happened when a tetromino locked
+type Clear struct {
+	Lines int
+	TSpin TSpin
+	// Combo counts the locks in a row that cleared lines, after the first one
+	Combo int
+	// BackToBack is true if this clear and the last one that cleared lines were both difficult
+	BackToBack bool
+	// PerfectClear is true if the board is empty after clearing lines
+	PerfectClear bool
+}
+
+// IsDifficult returns true for clears that continue a back-to-back chain:
+// Tetrises, and T-spins that clear lines.
+func (c Clear) IsDifficult() bool {
+	return c.Lines >= 4 || (c.Lines > 0 && c.TSpin != TSpin_None)
+}
+
+// ScoringRule decides how many points each action is worth
+type ScoringRule interface {
+	// ClearPoints returns the points for locking a tetromino, at the level it locked on
+	ClearPoints(c Clear, level int) int
+	// DropPoints returns the points for dropping a tetromino the given number of cells
+	DropPoints(cells int, isHardDrop bool) int
+}
+
+type ScoringKind int
+
+const (
+	// Guideline: Points from the Tetris Guideline, with T-spins, combos, back-to-back and perfect clears.
+	Scoring_Guideline ScoringKind = iota
+	// NES: Points for lines only, like the NES.
+	Scoring_NES
+	// TGM: Points for lines, the level and combos, like TGM.
+	Scoring_TGM
+)
+
+var scoringNames = [...]string{
+	Scoring_Guideline: "guideline",
+	Scoring_NES:       "nes",
+	Scoring_TGM:       "tgm",
+}
+
+// ScoringKinds lists every kind of scoring rule, in order
+var ScoringKinds = [...]ScoringKind{Scoring_Guideline, Scoring_NES, Scoring_TGM}
+
+func (k ScoringKind) String() string {
+	if k < 0 || int(k) >= len(scoringNames) {
+		return fmt.Sprintf("ScoringKind(%d)", int(k))
+	}
+
+	return scoringNames[k]
+}
+
+// ParseScoringKind is the inverse of ScoringKind.String
+func ParseScoringKind(name string) (ScoringKind, error) {
+	for k, n := range scoringNames {
+		if n == name {
+			return ScoringKind(k), nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown scoring %q", name)
+}
+
+func (k ScoringKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+func (k *ScoringKind) UnmarshalText(text []byte) (err error) {
+	*k, err = ParseScoringKind(string(text))
+	return err
+}
+
+func NewScoringRule(kind ScoringKind) ScoringRule {
+	switch kind {
+	case Scoring_Guideline:
+		return guidelineScoring{}
+	case Scoring_NES:
+		return nesScoring{}
+	case Scoring_TGM:
+		return tgmScoring{}
+	}
+
+	panic(fmt.Sprintf("NewScoringRule: invalid kind %v", kind))
+}
+
+//// Guideline
+
+type guidelineScoring struct{}
+
+// Indexed by lines cleared
+var (
+	guidelineLinePoints      = [...]int{0, 100, 300, 500, 800}
+	guidelineMiniTSpinPoints = [...]int{100, 200, 400}
+	guidelineTSpinPoints     = [...]int{400, 800, 1200, 1600}
+	guidelinePerfectPoints   = [...]int{0, 800, 1200, 1800, 2000}
+)
+
+const (
+	guidelineComboPoints             int = 50
+	guidelineBackToBackPerfectPoints int = 3200
+)
+
+func (guidelineScoring) ClearPoints(c Clear, level int) int {
+	var points int
+	switch c.TSpin {
+	case TSpin_None:
+		points = guidelineLinePoints[min(c.Lines, 4)]
+	case TSpin_Mini:
+		points = guidelineMiniTSpinPoints[min(c.Lines, 2)]
+	case TSpin_Full:
+		points = guidelineTSpinPoints[min(c.Lines, 3)]
+	}
+
+	if c.BackToBack {
+		points = points * 3 / 2
+	}
+
+	if c.PerfectClear {
+		if c.BackToBack && c.Lines >= 4 {
+			points += guidelineBackToBackPerfectPoints
+		} else {
+			points += guidelinePerfectPoints[min(c.Lines, 4)]
+		}
+	}
+
+	points += guidelineComboPoints * c.Combo
+
+	return points * level
+}
+
+func (guidelineScoring) DropPoints(cells int, isHardDrop bool) int {
+	if isHardDrop {
+		return cells * 2
+	}
+
+	return cells
+}
+
+//// NES
+
+type nesScoring struct{}
+
+var nesLinePoints = [...]int{0, 40, 100, 300, 1200}
+
+func (nesScoring) ClearPoints(c Clear, level int) int {
+	// The NES starts at level 0
+	return nesLinePoints[min(c.Lines, 4)] * level
+}
+
+func (nesScoring) DropPoints(cells int, isHardDrop bool) int {
+	// The NES has no hard drop, so it's scored like a soft drop
+	return cells
+}
+
+//// TGM
+
+type tgmScoring struct{}
+
+func (tgmScoring) ClearPoints(c Clear, level int) int {
+	if c.Lines == 0 {
+		return 0
+	}
+
+	// TGM's combo only grows on multi-line clears,
+	// here it's every lock in a row that cleared lines
+	points := (level + c.Lines + 3) / 4 * c.Lines * (c.Combo + 1)
+	if c.PerfectClear {
+		// Bravo
+		points *= 4
+	}
+
+	return points
+}
+
+func (tgmScoring) DropPoints(cells int, isHardDrop bool) int {
+	return cells
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+
+	return b
+}
+
+//// Scoring the game
+
+// tSpinCorners are the corners around a T-tetromino's origin,
+// the first two are in front of it, in each orientation
+var tSpinCorners = [4][4][2]int32{
+	Rotation_Spawn:   {{-1, 1}, {1, 1}, {-1, -1}, {1, -1}},
+	Rotation_Right:   {{1, 1}, {1, -1}, {-1, 1}, {-1, -1}},
+	Rotation_Flipped: {{-1, -1}, {1, -1}, {-1, 1}, {1, 1}},
+	Rotation_Left:    {{-1, 1}, {-1, -1}, {1, 1}, {1, -1}},
+}
+
+// tSpinFullKick is the kick that always makes a full T-spin, even with only one front corner filled
+const tSpinFullKick int = 4
+
+// detectTSpin checks if the active tetromino is T-spinning into place, using the 3-corner rule
+func (gs *GameState) detectTSpin() TSpin {
+	t := gs.ActiveTetromino
+	if t.Kind != Kind_T || !gs.lastMoveWasRotation {
+		return TSpin_None
+	}
+
+	isCornerFilled := func(corner [2]int32) bool {
+		x, y := t.OriginX+corner[0], t.OriginY+corner[1]
+		if x < 0 || x >= BoardCellsX || y < 0 || y >= BoardCellsY {
+			// Walls and floor count as filled
+			return true
+		}
+		return gs.Board[y][x].IsFilled
+	}
+
+	front, back := 0, 0
+	for i, corner := range tSpinCorners[t.Rotation] {
+		if !isCornerFilled(corner) {
+			continue
+		}
+		if i < 2 {
+			front++
+		} else {
+			back++
+		}
+	}
+
+	if front+back < 3 {
+		return TSpin_None
+	}
+	if front == 2 || gs.lastKick == tSpinFullKick {
+		return TSpin_Full
+	}
+	return TSpin_Mini
+}
+
+// scoreLock awards points for the locked tetromino, and keeps track of combos and back-to-backs
+func (gs *GameState) scoreLock(lines int) {
+	level := gs.Level()
+
+	clear := Clear{
+		Lines: lines,
+		TSpin: gs.lockTSpin,
+	}
+
+	if lines > 0 {
+		gs.combo++
+		clear.Combo = gs.combo
+
+		clear.BackToBack = clear.IsDifficult() && gs.isBackToBackReady
+		gs.isBackToBackReady = clear.IsDifficult()
+
+		clear.PerfectClear = gs.isBoardEmpty()
+	} else {
+		gs.combo = -1
+	}
+
+	if lines > 0 || clear.TSpin != TSpin_None {
+		gs.LastClear = clear
+	}
+
+	gs.linesCleared += lines
+	for i := 0; i < lines; i++ {
+		gs.lineFrames = append(gs.lineFrames, gs.frames)
+	}
+	gs.Score += gs.scoringRule.ClearPoints(clear, level)
+}
+
+// isBoardEmpty returns true if no cells are filled.
+// Rows being cleared are already marked as empty.
+func (gs *GameState) isBoardEmpty() bool {
+	for _, row := range gs.Board {
+		for _, cell := range row {
+			if cell.IsFilled {
+				return false
+			}
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,123 @@
+package engine
+
+import (
+	"testing"
+)
+
+func TestClearPoints(t *testing.T) {
+	tests := []struct {
+		name    string
+		scoring ScoringKind
+		clear   Clear
+		level   int
+		want    int
+	}{
+		// Guideline
+		{"guideline nothing", Scoring_Guideline, Clear{}, 1, 0},
+		{"guideline single", Scoring_Guideline, Clear{Lines: 1}, 1, 100},
+		{"guideline double", Scoring_Guideline, Clear{Lines: 2}, 1, 300},
+		{"guideline triple", Scoring_Guideline, Clear{Lines: 3}, 1, 500},
+		{"guideline tetris at level 2", Scoring_Guideline, Clear{Lines: 4}, 2, 1600},
+		{"guideline t-spin", Scoring_Guideline, Clear{TSpin: TSpin_Full}, 1, 400},
+		{"guideline t-spin double", Scoring_Guideline, Clear{Lines: 2, TSpin: TSpin_Full}, 1, 1200},
+		{"guideline t-spin triple", Scoring_Guideline, Clear{Lines: 3, TSpin: TSpin_Full}, 1, 1600},
+		{"guideline mini t-spin", Scoring_Guideline, Clear{TSpin: TSpin_Mini}, 1, 100},
+		{"guideline mini t-spin single", Scoring_Guideline, Clear{Lines: 1, TSpin: TSpin_Mini}, 1, 200},
+		{"guideline back-to-back tetris", Scoring_Guideline, Clear{Lines: 4, BackToBack: true}, 1, 1200},
+		{"guideline back-to-back t-spin double", Scoring_Guideline, Clear{Lines: 2, TSpin: TSpin_Full, BackToBack: true}, 1, 1800},
+		{"guideline combo", Scoring_Guideline, Clear{Lines: 1, Combo: 3}, 1, 250},
+		{"guideline combo at level 3", Scoring_Guideline, Clear{Lines: 2, Combo: 2}, 3, 1200},
+		{"guideline perfect single", Scoring_Guideline, Clear{Lines: 1, PerfectClear: true}, 1, 900},
+		{"guideline perfect tetris", Scoring_Guideline, Clear{Lines: 4, PerfectClear: true}, 1, 2800},
+		{"guideline back-to-back perfect tetris", Scoring_Guideline, Clear{Lines: 4, BackToBack: true, PerfectClear: true}, 1, 4400},
+
+		// NES, which ignores everything but lines
+		{"nes nothing", Scoring_NES, Clear{}, 1, 0},
+		{"nes single at level 5", Scoring_NES, Clear{Lines: 1}, 5, 200},
+		{"nes double at level 3", Scoring_NES, Clear{Lines: 2}, 3, 300},
+		{"nes triple at level 2", Scoring_NES, Clear{Lines: 3}, 2, 600},
+		{"nes tetris", Scoring_NES, Clear{Lines: 4}, 1, 1200},
+		{"nes t-spin double", Scoring_NES, Clear{Lines: 2, TSpin: TSpin_Full, Combo: 3, BackToBack: true}, 1, 100},
+
+		// TGM
+		{"tgm nothing", Scoring_TGM, Clear{TSpin: TSpin_Full}, 10, 0},
+		{"tgm single", Scoring_TGM, Clear{Lines: 1}, 1, 1},
+		{"tgm tetris at level 10", Scoring_TGM, Clear{Lines: 4}, 10, 16},
+		{"tgm combo double at level 100", Scoring_TGM, Clear{Lines: 2, Combo: 1}, 100, 104},
+		{"tgm bravo tetris", Scoring_TGM, Clear{Lines: 4, PerfectClear: true}, 1, 32},
+	}
+
+	for _, tt := range tests {
+		if got := NewScoringRule(tt.scoring).ClearPoints(tt.clear, tt.level); got != tt.want {
+			t.Errorf("%s: ClearPoints(%+v, %d) = %d, want %d", tt.name, tt.clear, tt.level, got, tt.want)
+		}
+	}
+}
+
+func TestDropPoints(t *testing.T) {
+	tests := []struct {
+		scoring    ScoringKind
+		cells      int
+		isHardDrop bool
+		want       int
+	}{
+		{Scoring_Guideline, 5, false, 5},
+		{Scoring_Guideline, 5, true, 10},
+		{Scoring_NES, 5, false, 5},
+		{Scoring_NES, 5, true, 5},
+		{Scoring_TGM, 5, false, 5},
+		{Scoring_TGM, 5, true, 5},
+		{Scoring_Guideline, 0, true, 0},
+	}
+
+	for _, tt := range tests {
+		if got := NewScoringRule(tt.scoring).DropPoints(tt.cells, tt.isHardDrop); got != tt.want {
+			t.Errorf("%v: DropPoints(%d, %v) = %d, want %d", tt.scoring, tt.cells, tt.isHardDrop, got, tt.want)
+		}
+	}
+}
+
+func TestDetectTSpin(t *testing.T) {
+	// The corners of a T pointing up, at (4, 1)
+	var (
+		frontLeft  = [2]int32{3, 2}
+		frontRight = [2]int32{5, 2}
+		backLeft   = [2]int32{3, 0}
+		backRight  = [2]int32{5, 0}
+	)
+
+	tests := []struct {
+		name      string
+		kind      Kind
+		originY   int32
+		isRotated bool
+		kick      int
+		filled    [][2]int32
+		want      TSpin
+	}{
+		{"not rotated", Kind_T, 1, false, 0, [][2]int32{frontLeft, frontRight, backLeft}, TSpin_None},
+		{"not a T", Kind_L, 1, true, 0, [][2]int32{frontLeft, frontRight, backLeft}, TSpin_None},
+		{"two corners", Kind_T, 1, true, 0, [][2]int32{frontLeft, backLeft}, TSpin_None},
+		{"both front corners", Kind_T, 1, true, 0, [][2]int32{frontLeft, frontRight, backRight}, TSpin_Full},
+		{"one front corner", Kind_T, 1, true, 0, [][2]int32{frontLeft, backLeft, backRight}, TSpin_Mini},
+		{"one front corner after the last kick", Kind_T, 1, true, tSpinFullKick, [][2]int32{frontLeft, backLeft, backRight}, TSpin_Full},
+		{"every corner", Kind_T, 1, true, 0, [][2]int32{frontLeft, frontRight, backLeft, backRight}, TSpin_Full},
+		// On the floor, the back corners are out of the board and count as filled
+		{"on the floor", Kind_T, 0, true, 0, [][2]int32{{3, 1}}, TSpin_Mini},
+	}
+
+	for _, tt := range tests {
+		gs := &GameState{
+			ActiveTetromino:     &Tetromino{OriginX: 4, OriginY: tt.originY, Kind: tt.kind, Rotation: Rotation_Spawn},
+			lastMoveWasRotation: tt.isRotated,
+			lastKick:            tt.kick,
+		}
+		for _, c := range tt.filled {
+			gs.Board[c[1]][c[0]].IsFilled = true
+		}
+
+		if got := gs.detectTSpin(); got != tt.want {
+			t.Errorf("%s: detectTSpin() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
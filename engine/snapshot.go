@@ -0,0 +1,99 @@
+package engine
+
+// Snapshot is a copy of everything a placement changes, taken when a tetromino spawns.
+// Restoring it puts the game back to the moment that tetromino spawned.
+type Snapshot struct {
+	activeTetromino  Tetromino
+	holdingTetromino Kind
+	tetrominoQueue   [TetrominoQueueSize]Kind
+	board            Board
+	randomizer       Randomizer
+
+	linesCleared      int
+	score             int
+	lastClear         Clear
+	combo             int
+	isBackToBackReady bool
+	lineFrames        []int
+}
+
+// Snapshot copies the game, while a tetromino is active
+func (gs *GameState) Snapshot() Snapshot {
+	return Snapshot{
+		activeTetromino:  *gs.ActiveTetromino,
+		holdingTetromino: gs.HoldingTetromino,
+		tetrominoQueue:   gs.TetrominoQueue,
+		board:            gs.Board,
+		randomizer:       gs.randomizer.Clone(),
+
+		linesCleared:      gs.linesCleared,
+		score:             gs.Score,
+		lastClear:         gs.LastClear,
+		combo:             gs.combo,
+		isBackToBackReady: gs.isBackToBackReady,
+		lineFrames:        append([]int(nil), gs.lineFrames...),
+	}
+}
+
+// Restore puts the game back to when the snapshot was taken.
+// The snapshot can be restored again later.
+func (gs *GameState) Restore(s Snapshot) {
+	active := s.activeTetromino
+	gs.ActiveTetromino = &active
+	gs.HoldingTetromino = s.holdingTetromino
+	gs.TetrominoQueue = s.tetrominoQueue
+	gs.Board = s.board
+	gs.randomizer = s.randomizer.Clone()
+
+	gs.linesCleared = s.linesCleared
+	gs.Score = s.score
+	gs.LastClear = s.lastClear
+	gs.combo = s.combo
+	gs.isBackToBackReady = s.isBackToBackReady
+	gs.lineFrames = append([]int(nil), s.lineFrames...)
+
+	gs.Phase = Phase_Falling
+	gs.rowsToDelete = nil
+	gs.trailTimer = 0
+	gs.dropTimer = 0
+	gs.lastMoveWasRotation = false
+	gs.resetLockDelay()
+}
+
+//// Undo
+
+// pushUndo remembers the game as it is now, and forgets anything that was undone
+func (gs *GameState) pushUndo() {
+	gs.undoHistory = append(gs.undoHistory, gs.Snapshot())
+	gs.redoHistory = nil
+}
+
+// undo goes back to when the last tetromino spawned,
+// or restarts the active tetromino if it's the first
+func (gs *GameState) undo() {
+	if len(gs.undoHistory) == 0 {
+		return
+	}
+
+	if len(gs.undoHistory) > 1 {
+		last := len(gs.undoHistory) - 1
+		gs.redoHistory = append(gs.redoHistory, gs.undoHistory[last])
+		gs.undoHistory = gs.undoHistory[:last]
+	}
+
+	gs.Restore(gs.undoHistory[len(gs.undoHistory)-1])
+}
+
+// redo goes forward to the placement that was last undone
+func (gs *GameState) redo() {
+	if len(gs.redoHistory) == 0 {
+		return
+	}
+
+	last := len(gs.redoHistory) - 1
+	s := gs.redoHistory[last]
+	gs.redoHistory = gs.redoHistory[:last]
+	gs.undoHistory = append(gs.undoHistory, s)
+
+	gs.Restore(s)
+}
@@ -0,0 +1,250 @@
+package engine
+
+import (
+	"fmt"
+)
+
+// Kind identifies the shape of a tetromino.
+// Renderers decide what each kind looks like.
+type Kind int
+
+const (
+	// None: Empty cell, or no tetromino.
+	Kind_None Kind = iota
+	Kind_O
+	Kind_I
+	Kind_T
+	Kind_L
+	Kind_J
+	Kind_S
+	Kind_Z
+	// Garbage: Cells added from below, that weren't part of a tetromino.
+	Kind_Garbage
+)
+
+// Kinds lists every playable kind
+var Kinds = [7]Kind{Kind_O, Kind_I, Kind_T, Kind_L, Kind_J, Kind_S, Kind_Z}
+
+func (k Kind) String() string {
+	switch k {
+	case Kind_None:
+		return "none"
+	case Kind_O:
+		return "O"
+	case Kind_I:
+		return "I"
+	case Kind_T:
+		return "T"
+	case Kind_L:
+		return "L"
+	case Kind_J:
+		return "J"
+	case Kind_S:
+		return "S"
+	case Kind_Z:
+		return "Z"
+	case Kind_Garbage:
+		return "garbage"
+	}
+
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// Rotation is the orientation of a tetromino, relative to how it spawned
+type Rotation int
+
+const (
+	Rotation_Spawn Rotation = iota
+	Rotation_Right
+	Rotation_Flipped
+	Rotation_Left
+)
+
+// Rotated returns the orientation after rotating once in the given direction
+func (r Rotation) Rotated(clockwise bool) Rotation {
+	if clockwise {
+		return (r + 1) % 4
+	}
+
+	return (r + 3) % 4
+}
+
+type Tetromino struct {
+	// Origin is in gameboard space, not screen space
+	OriginX, OriginY int32
+	// Cells are offsets from the origin
+	Cells    [4][2]int32
+	Kind     Kind
+	Rotation Rotation
+}
+
+type cellIteratorFunction func(x, y int32) bool
+
+// cellIterator call the iterator function for each cell in the tetromino
+// The iterator function is given the absolute coordinates of the cell
+// If the iter function returns true, iteration is stopped and this function returns true
+// If no functions return true, this function returns false
+func (t *Tetromino) cellIterator(f cellIteratorFunction) bool {
+	for _, cell := range t.Cells {
+		if ok := f(cell[0]+t.OriginX, cell[1]+t.OriginY); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsCell given coordinates in gameboard space, returns true if one of the tetrominos blocks is in those coordinates
+func (t *Tetromino) IsCell(x, y int32) (Kind, bool) {
+	return t.Kind, t.cellIterator(func(cx, cy int32) bool {
+		return cx == x && cy == y
+	})
+}
+
+// CheckCollision returns true if the tetromino is colliding with the gameboard
+func (t *Tetromino) CheckCollision(b *Board) bool {
+	return t.cellIterator(func(x, y int32) bool {
+		// Check if the cell is outside the gameboard
+		if x < 0 || x >= BoardCellsX || y < 0 || y >= BoardCellsY {
+			return true
+		}
+
+		// Check if the cell is already filled
+		return b[y][x].IsFilled
+	})
+}
+
+// CommitToBoard copies each cell of tetromino to the gameboard
+func (t *Tetromino) CommitToBoard(b *Board) {
+	t.cellIterator(func(x, y int32) bool {
+		b[y][x].IsFilled = true
+		b[y][x].Kind = t.Kind
+		return false
+	})
+}
+
+// CommitTrailToBoard copies each cell of the tetromino as a trail to the gameboard
+func (t *Tetromino) CommitTrailToBoard(b *Board) {
+	t.cellIterator(func(x, y int32) bool {
+		b[y][x].IsGhost = true
+		b[y][x].Kind = t.Kind
+		return false
+	})
+}
+
+// RotateClockwise turns the cells around the origin, without checking for collisions
+func (t *Tetromino) RotateClockwise() {
+	// To rotate clockwise,
+	// first swap the x and y components,
+	// then invert the y component
+	for i := 0; i < 4; i++ {
+		t.Cells[i][0], t.Cells[i][1] = t.Cells[i][1], -t.Cells[i][0]
+	}
+	t.Rotation = t.Rotation.Rotated(true)
+}
+
+// RotateCounterClockwise turns the cells around the origin, without checking for collisions
+func (t *Tetromino) RotateCounterClockwise() {
+	// To rotate counter clockwise,
+	// do the opposite of the clockwise rotation
+	for i := 0; i < 4; i++ {
+		t.Cells[i][0], t.Cells[i][1] = -t.Cells[i][1], t.Cells[i][0]
+	}
+	t.Rotation = t.Rotation.Rotated(false)
+}
+
+// newClassicTetromino creates a tetromino of the given kind, in its classic spawn orientation
+func newClassicTetromino(kind Kind, originX, originY int32) *Tetromino {
+	// Note cells are defined in clockwise order
+	switch kind {
+	case Kind_O:
+		return &Tetromino{
+			OriginX: originX,
+			OriginY: originY,
+			Kind:    kind,
+			Cells: [4][2]int32{
+				{0, 0},
+				{0, 1},
+				{1, 1},
+				{1, 0},
+			},
+		}
+	case Kind_I:
+		// Note: In the real tetris, the I-tetromino's origin is
+		//       in the center of 4 cells, not the cengter-left block.
+		//       It's an edge case we won't care about for now.
+		return &Tetromino{
+			OriginX: originX,
+			OriginY: originY,
+			Kind:    kind,
+			Cells: [4][2]int32{
+				{0, 0},
+				{-1, 0},
+				{1, 0},
+				{2, 0},
+			},
+		}
+	case Kind_T:
+		return &Tetromino{
+			OriginX: originX,
+			OriginY: originY,
+			Kind:    kind,
+			Cells: [4][2]int32{
+				{0, 0},
+				{-1, 0},
+				{0, 1},
+				{1, 0},
+			},
+		}
+	case Kind_L:
+		return &Tetromino{
+			OriginX: originX,
+			OriginY: originY,
+			Kind:    kind,
+			Cells: [4][2]int32{
+				{0, 0},
+				{-1, 0},
+				{1, 1},
+				{1, 0},
+			},
+		}
+	case Kind_J:
+		return &Tetromino{
+			OriginX: originX,
+			OriginY: originY,
+			Kind:    kind,
+			Cells: [4][2]int32{
+				{0, 0},
+				{-1, 0},
+				{-1, -1},
+				{1, 0},
+			},
+		}
+	case Kind_S:
+		return &Tetromino{
+			OriginX: originX,
+			OriginY: originY,
+			Kind:    kind,
+			Cells: [4][2]int32{
+				{0, 0},
+				{-1, 0},
+				{0, 1},
+				{1, 1},
+			},
+		}
+	case Kind_Z:
+		return &Tetromino{
+			OriginX: originX,
+			OriginY: originY,
+			Kind:    kind,
+			Cells: [4][2]int32{
+				{0, 0},
+				{-1, 1},
+				{0, 1},
+				{1, 0},
+			},
+		}
+	}
+
+	panic(fmt.Sprintf("newClassicTetromino: invalid kind %v", kind))
+}
@@ -0,0 +1,57 @@
+package engine
+
+// Gravity is how fast the active tetromino falls, Cells every Frames frames.
+// It can be more than one cell each frame, 20 cells each frame drops straight to the floor.
+type Gravity struct {
+	Cells  int
+	Frames int
+}
+
+// GravityUnit is one cell per frame, in the units TGM uses.
+// Gravity{Cells: 4, Frames: GravityUnit} is 4/256 G.
+const GravityUnit int = 256
+
+// Timing is how fast the game runs, modes can change it as the game goes on
+type Timing struct {
+	Gravity Gravity
+	// ARE is the number of frames before the next tetromino spawns
+	ARE int
+	// LineARE replaces ARE after rows were cleared
+	LineARE int
+	// LineClearDelay is the number of frames cleared rows are shown before they're deleted
+	LineClearDelay int
+	// LockDelay is the number of frames a tetromino can rest on something before it locks
+	LockDelay int
+	// DAS is the number of frames left or right is held before it repeats
+	DAS int
+}
+
+// standardTiming uses the rules, handling and guideline gravity for the current level
+func (gs *GameState) standardTiming() Timing {
+	return Timing{
+		Gravity:        Gravity{Cells: 1, Frames: gs.DropInterval(1)},
+		ARE:            gs.Rules.GenerationDelay,
+		LineARE:        gs.Rules.GenerationDelay,
+		LineClearDelay: gs.Rules.RowClearDelay,
+		LockDelay:      gs.Rules.LockDelay,
+		DAS:            gs.Handling.DAS,
+	}
+}
+
+// fall moves the active tetromino down as far as gravity takes it this frame.
+// Returns the number of cells it fell.
+func (gs *GameState) fall(g Gravity) int {
+	cells := 0
+
+	gs.dropTimer += g.Cells
+	for gs.dropTimer >= g.Frames {
+		gs.dropTimer -= g.Frames
+		if didCollide := gs.ActiveTetrominoDown(); didCollide {
+			gs.dropTimer = 0
+			break
+		}
+		cells++
+	}
+
+	return cells
+}
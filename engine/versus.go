@@ -0,0 +1,311 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+)
+
+//// Attack
+
+// AttackTable decides how many rows of garbage a clear sends to the opponent
+type AttackTable struct {
+	Single, Double, Triple, Tetris   int
+	TSpinMiniSingle, TSpinMiniDouble int
+	TSpinSingle, TSpinDouble         int
+	TSpinTriple                      int
+
+	// BackToBack is added to clears that continue a back-to-back chain
+	BackToBack int
+	// Combo is added for each lock in a row that cleared lines, after the first one.
+	// The last one is used for longer combos.
+	Combo []int
+	// PerfectClear is added when the board is left empty
+	PerfectClear int
+}
+
+// DefaultAttackTable follows modern guideline games
+func DefaultAttackTable() AttackTable {
+	return AttackTable{
+		Single: 0, Double: 1, Triple: 2, Tetris: 4,
+		TSpinMiniSingle: 0, TSpinMiniDouble: 1,
+		TSpinSingle: 2, TSpinDouble: 4, TSpinTriple: 6,
+		BackToBack:   1,
+		Combo:        []int{1, 1, 2, 2, 3, 3, 4, 4, 4, 5},
+		PerfectClear: 10,
+	}
+}
+
+// Attack returns the rows of garbage a clear sends
+func (a AttackTable) Attack(c Clear) int {
+	if c.Lines == 0 {
+		return 0
+	}
+
+	var rows int
+	switch c.TSpin {
+	case TSpin_Full:
+		rows = [...]int{a.TSpinSingle, a.TSpinDouble, a.TSpinTriple}[min(c.Lines, 3)-1]
+	case TSpin_Mini:
+		rows = [...]int{a.TSpinMiniSingle, a.TSpinMiniDouble}[min(c.Lines, 2)-1]
+	default:
+		rows = [...]int{a.Single, a.Double, a.Triple, a.Tetris}[min(c.Lines, 4)-1]
+	}
+
+	if c.BackToBack {
+		rows += a.BackToBack
+	}
+	if c.Combo > 0 && len(a.Combo) > 0 {
+		rows += a.Combo[min(c.Combo, len(a.Combo))-1]
+	}
+	if c.PerfectClear {
+		rows += a.PerfectClear
+	}
+
+	return rows
+}
+
+//// Garbage meter
+
+// PendingGarbage is garbage sent by the opponent, waiting in the meter
+type PendingGarbage struct {
+	Rows int
+	// ReadyFrame is the frame it can rise on, once the player locks without clearing lines
+	ReadyFrame int
+}
+
+// IncomingGarbage returns the garbage waiting in the meter, oldest first
+func (gs *GameState) IncomingGarbage() []PendingGarbage {
+	return gs.incomingGarbage
+}
+
+// IsReady returns true if the garbage can rise in the game
+func (p PendingGarbage) IsReady(gs *GameState) bool {
+	return gs.frames >= p.ReadyFrame
+}
+
+// GarbageSent returns how many rows of garbage were sent to the opponent, after cancelling
+func (gs *GameState) GarbageSent() int {
+	return gs.garbageSent
+}
+
+// receiveGarbage adds garbage to the meter, ready after delay frames
+func (gs *GameState) receiveGarbage(rows, delay int) {
+	gs.incomingGarbage = append(gs.incomingGarbage, PendingGarbage{Rows: rows, ReadyFrame: gs.frames + delay})
+}
+
+// cancelGarbage takes an attack away from the garbage in the meter, oldest first.
+// Returns what's left of the attack.
+func (gs *GameState) cancelGarbage(attack int) int {
+	for attack > 0 && len(gs.incomingGarbage) > 0 {
+		cancelled := min(attack, gs.incomingGarbage[0].Rows)
+		attack -= cancelled
+		gs.incomingGarbage[0].Rows -= cancelled
+		if gs.incomingGarbage[0].Rows == 0 {
+			gs.incomingGarbage = gs.incomingGarbage[1:]
+		}
+	}
+
+	return attack
+}
+
+// takeOutgoingGarbage returns the rows to send to the opponent, and forgets them
+func (gs *GameState) takeOutgoingGarbage() int {
+	rows := gs.outgoingGarbage
+	gs.outgoingGarbage = 0
+	gs.garbageSent += rows
+	return rows
+}
+
+//// Versus
+
+// VersusMode is each player's mode in a Versus match.
+// Clears attack the opponent, first cancelling garbage in the player's meter.
+// Garbage that's ready rises when the player locks without clearing lines.
+type VersusMode struct {
+	Attack AttackTable
+	// GarbageDelay is the number of frames garbage waits in the meter before it can rise
+	GarbageDelay int
+	// Messiness is the chance, from 0 to 1, of the hole moving between rows of the same attack.
+	// Every attack gets a new hole.
+	Messiness float64
+}
+
+// DefaultVersusMode sends garbage by the default attack table, which can rise after a second
+func DefaultVersusMode() VersusMode {
+	return VersusMode{
+		Attack:       DefaultAttackTable(),
+		GarbageDelay: Frames(time.Second),
+		Messiness:    0,
+	}
+}
+
+// Check returns an error if the mode can't be played, like an attack with negative rows
+func (m VersusMode) Check() error {
+	a := m.Attack
+	rows := append([]int{
+		a.Single, a.Double, a.Triple, a.Tetris, a.TSpinMiniSingle, a.TSpinMiniDouble,
+		a.TSpinSingle, a.TSpinDouble, a.TSpinTriple, a.BackToBack, a.PerfectClear,
+	}, a.Combo...)
+	for _, n := range rows {
+		if n < 0 {
+			return fmt.Errorf("attacks can't send negative rows")
+		}
+	}
+
+	switch {
+	case m.GarbageDelay < 0:
+		return fmt.Errorf("garbage delay can't be negative")
+	case m.Messiness < 0 || m.Messiness > 1:
+		return fmt.Errorf("messiness must be from 0 to 1")
+	}
+
+	return nil
+}
+
+func (VersusMode) Name() string {
+	return "versus"
+}
+
+func (VersusMode) Level(gs *GameState) int {
+	return 1
+}
+
+// IsFinished is false, the match decides who wins
+func (VersusMode) IsFinished(gs *GameState) bool {
+	return false
+}
+
+func (VersusMode) Timing(gs *GameState) Timing {
+	return gs.standardTiming()
+}
+
+func (VersusMode) Start(gs *GameState)   {}
+func (VersusMode) Spawned(gs *GameState) {}
+
+func (m VersusMode) Completed(gs *GameState, lines int) {
+	if lines > 0 {
+		gs.outgoingGarbage += gs.cancelGarbage(m.Attack.Attack(gs.LastClear))
+		return
+	}
+
+	for len(gs.incomingGarbage) > 0 && gs.incomingGarbage[0].IsReady(gs) && gs.Phase != Phase_GameOver {
+		rows := gs.incomingGarbage[0].Rows
+		gs.incomingGarbage = gs.incomingGarbage[1:]
+
+		// The first row always moves the hole, so each attack has its own
+		gs.AddGarbage(1, 1)
+		gs.AddGarbage(rows-1, m.Messiness)
+	}
+}
+
+func (VersusMode) ToppedOut(gs *GameState) bool {
+	return true
+}
+
+// VersusPlayers is the number of players in a versus match
+const VersusPlayers = 2
+
+// NoWinner is the winner of a match that isn't over, or was drawn
+const NoWinner = -1
+
+// Versus is a match between two players, each playing their own game.
+// It's stepped like a game, with the inputs of both players told apart by InputEvent.Player.
+// The first player to top out loses.
+type Versus struct {
+	Players [VersusPlayers]*GameState
+	Mode    VersusMode
+
+	// IsPaused is true while the match is paused, either player can pause it
+	IsPaused bool
+	// IsOver is true once a player has topped out
+	IsOver bool
+	// Winner is the player that won, or NoWinner if both topped out together
+	Winner int
+
+	playerInputs [VersusPlayers][]InputEvent
+}
+
+// NewVersus starts a match. Both players are dealt the same tetrominos.
+// Each player keeps their own handling.
+func NewVersus(rules Rules, handling [VersusPlayers]Handling, mode VersusMode, seed int64) *Versus {
+	v := &Versus{Mode: mode, Winner: NoWinner}
+	for i := range v.Players {
+		v.Players[i] = NewGameState(rules, handling[i], mode, seed)
+	}
+
+	return v
+}
+
+// IsDone returns true once the match is over and a player has moved on from it
+func (v *Versus) IsDone() bool {
+	if !v.IsOver {
+		return false
+	}
+	for _, gs := range v.Players {
+		if gs.IsDone {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (v *Versus) Step(inputs []InputEvent) {
+	for i := range v.playerInputs {
+		v.playerInputs[i] = v.playerInputs[i][:0]
+	}
+	for _, event := range inputs {
+		if event.Player < 0 || event.Player >= VersusPlayers {
+			continue
+		}
+		// The match is paused instead of the game, so both games pause together
+		if event.Input == Input_Pause && !v.IsOver {
+			if event.Action == Action_Up {
+				v.IsPaused = !v.IsPaused
+			}
+			continue
+		}
+		v.playerInputs[event.Player] = append(v.playerInputs[event.Player], event)
+	}
+
+	if v.IsPaused {
+		// Keep track of what's held, so nothing is stuck after the pause
+		for i, gs := range v.Players {
+			gs.trackHeldInputs(v.playerInputs[i])
+		}
+		return
+	}
+
+	for i, gs := range v.Players {
+		gs.Step(v.playerInputs[i])
+	}
+	if v.IsOver {
+		return
+	}
+
+	// Garbage is sent once both players have stepped, so neither is a frame ahead
+	for i, gs := range v.Players {
+		if rows := gs.takeOutgoingGarbage(); rows > 0 {
+			v.Players[1-i].receiveGarbage(rows, v.Mode.GarbageDelay)
+		}
+	}
+
+	toppedOut := 0
+	for i, gs := range v.Players {
+		if gs.Phase == Phase_GameOver {
+			toppedOut++
+		} else {
+			v.Winner = i
+		}
+	}
+	switch toppedOut {
+	case 0:
+		v.Winner = NoWinner
+	case VersusPlayers:
+		v.IsOver = true
+		v.Winner = NoWinner
+	default:
+		v.IsOver = true
+		v.Players[v.Winner].Phase = Phase_Finished
+	}
+}
@@ -2,11 +2,15 @@ package main
 
 import (
 	"fmt"
+	"time"
 
 	rl "github.com/gen2brain/raylib-go/raylib"
+
+	"getris/engine"
 )
 
-func drawCenteredText(text string) {
+// drawCenteredText draws a big message centered on (centerX, 0)
+func drawCenteredText(text string, centerX int32) {
 	font := rl.GetFontDefault()
 	size := rl.MeasureTextEx(
 		font,
@@ -19,7 +23,7 @@ func drawCenteredText(text string) {
 		font,
 		text,
 		rl.Vector2{
-			X: -(size.X / 2),
+			X: float32(centerX) - (size.X / 2),
 			Y: -(size.Y / 2),
 		},
 		titleTextSize,
@@ -28,6 +32,28 @@ func drawCenteredText(text string) {
 	)
 }
 
+// drawCenteredTextLine draws text centered horizontally, with its top at y
+func drawCenteredTextLine(text string, y, size int32, color rl.Color) {
+	width := rl.MeasureText(text, size)
+	rl.DrawText(text, -(width / 2), y, size, color)
+}
+
+// formatTime formats a duration as m:ss.mmm
+func formatTime(d time.Duration) string {
+	return fmt.Sprintf("%d:%02d.%03d", int(d.Minutes()), int(d.Seconds())%60, d.Milliseconds()%1000)
+}
+
+// formatTimeDifference formats a difference between two times as +s.mmm or -s.mmm
+func formatTimeDifference(d time.Duration) string {
+	sign := "+"
+	if d < 0 {
+		sign = "-"
+		d = -d
+	}
+
+	return fmt.Sprintf("%s%d.%03d", sign, int(d.Seconds()), d.Milliseconds()%1000)
+}
+
 func drawBorderedRectangle(x, y, width, height int32, backgroundColor, outlineColor rl.Color) {
 	rl.DrawRectangle(x, y, width, height, backgroundColor)
 	rl.DrawRectangleLines(x, y, width, height, outlineColor)
@@ -36,9 +62,9 @@ func drawBorderedRectangle(x, y, width, height int32, backgroundColor, outlineCo
 // Draw is intended to be called from the render loop
 type drawBoardCellCallback func(gridX, gridY, screenX, screenY int32) (color rl.Color, cellFilled bool)
 
-func drawBoard(bottomLeftX, bottomLeftY, cellsX, cellsY int32, fn drawBoardCellCallback) {
-	boardSizeX := cellsX * cellSizeX
-	boardSizeY := cellsY * cellSizeY
+func (l *layout) drawBoard(bottomLeftX, bottomLeftY, cellsX, cellsY int32, fn drawBoardCellCallback) {
+	boardSizeX := cellsX * l.cellSize
+	boardSizeY := cellsY * l.cellSize
 
 	drawBorderedRectangle(
 		bottomLeftX, (bottomLeftY - boardSizeY),
@@ -49,13 +75,13 @@ func drawBoard(bottomLeftX, bottomLeftY, cellsX, cellsY int32, fn drawBoardCellC
 
 	for gridY := int32(0); gridY < cellsY; gridY++ {
 		for gridX := int32(0); gridX < cellsX; gridX++ {
-			screenX := bottomLeftX + (cellSizeX * gridX)
-			screenY := bottomLeftY - (cellSizeY * (gridY + 1))
+			screenX := bottomLeftX + (l.cellSize * gridX)
+			screenY := bottomLeftY - (l.cellSize * (gridY + 1))
 
 			if color, isFilled := fn(gridX, gridY, screenX, screenY); isFilled {
 				drawBorderedRectangle(
 					screenX, screenY,
-					cellSizeX, cellSizeY,
+					l.cellSize, l.cellSize,
 					color,
 					boardColor,
 				)
@@ -64,44 +90,123 @@ func drawBoard(bottomLeftX, bottomLeftY, cellsX, cellsY int32, fn drawBoardCellC
 	}
 }
 
-func (gs *gameState) Draw() {
-	gs.RLock()
-	defer gs.RUnlock()
-
-	gs.DrawMainBoard()
-
-	gs.DrawHoldingBoard()
+// drawTetromino returns the color of a tetromino's cell at the given grid coordinates
+func drawTetromino(t *engine.Tetromino, gridX, gridY int32) (color rl.Color, cellFilled bool) {
+	if kind, isFilled := t.IsCell(gridX, gridY); isFilled {
+		return tetrominoColors[kind], true
+	}
 
-	gs.DrawQueueBoard()
+	return rl.Color{}, false
+}
 
-	gs.DrawScore()
+func (l *layout) drawGame(gs *engine.GameState, score []scoreLine) {
+	l.drawBoards(gs, score)
 
 	// Draw paused message
 	switch gs.Phase {
-	case phase_Paused:
-		drawCenteredText(pausedText)
-	case phase_GameOver:
-		drawCenteredText(gameOverText)
+	case engine.Phase_Paused:
+		drawCenteredText(pausedText, l.centerX)
+		drawCenteredTextLine(quitGameText, int32(titleTextSize), menuTextSize, textColor)
+	case engine.Phase_GameOver:
+		drawCenteredText(gameOverText, l.centerX)
+	case engine.Phase_Finished:
+		drawCenteredText(finishedText, l.centerX)
+	}
+}
+
+// drawBoards draws the game's boards and score, without a message for the phase
+func (l *layout) drawBoards(gs *engine.GameState, score []scoreLine) {
+	l.drawMainBoard(gs)
+
+	if gs.Rules.Hold {
+		l.drawHoldingBoard(gs)
 	}
+
+	if len(gs.Previews()) > 0 {
+		l.drawQueueBoard(gs)
+	}
+
+	if len(gs.IncomingGarbage()) > 0 {
+		l.drawGarbageMeter(gs)
+	}
+
+	l.drawScore(score)
+}
+
+type ghostStyle int
+
+const (
+	// Filled: Draw the ghost tetromino as faded cells
+	ghostStyle_Filled ghostStyle = iota
+	// Outline: Draw only the outline of the ghost tetromino's cells
+	ghostStyle_Outline
+	// Off: Don't draw the ghost tetromino
+	ghostStyle_Off
+)
+
+var ghostStyleNames = [...]string{
+	ghostStyle_Filled:  "filled",
+	ghostStyle_Outline: "outline",
+	ghostStyle_Off:     "off",
+}
+
+func (g ghostStyle) String() string {
+	return ghostStyleNames[g]
+}
+
+func parseGhostStyle(name string) (ghostStyle, error) {
+	for g, n := range ghostStyleNames {
+		if n == name {
+			return ghostStyle(g), nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown ghost style %q", name)
 }
 
-func (gs *gameState) DrawMainBoard() {
-	drawBoard(
-		boardBottomLeftX, boardBottomLeftY,
-		boardCellsX, boardCellsY_Visible,
+func (g ghostStyle) MarshalText() ([]byte, error) {
+	return []byte(g.String()), nil
+}
+
+func (g *ghostStyle) UnmarshalText(text []byte) (err error) {
+	*g, err = parseGhostStyle(string(text))
+	return err
+}
+
+// drawGhostStyle is how the landing preview of the active tetromino is drawn
+var drawGhostStyle = ghostStyle_Filled
+
+func (l *layout) drawMainBoard(gs *engine.GameState) {
+	var ghost *engine.Tetromino
+	if drawGhostStyle != ghostStyle_Off {
+		ghost = gs.GhostTetromino()
+	}
+
+	l.drawBoard(
+		l.boardBottomLeftX, l.boardBottomLeftY,
+		engine.BoardCellsX, engine.BoardCellsY_Visible,
 		func(gridX, gridY, screenX, screenY int32) (color rl.Color, cellFilled bool) {
 			if gs.ActiveTetromino != nil {
-				if color, isFilled := gs.ActiveTetromino.IsCell(gridX, gridY); isFilled {
+				if color, isFilled := drawTetromino(gs.ActiveTetromino, gridX, gridY); isFilled {
 					return color, isFilled
 				}
 			}
 
 			cell := gs.Board[gridY][gridX]
 			if cell.IsFilled {
-				return cell.Color, true
+				return tetrominoColors[cell.Kind], true
+			}
+			if ghost != nil {
+				if color, isFilled := drawTetromino(ghost, gridX, gridY); isFilled {
+					if drawGhostStyle == ghostStyle_Outline {
+						rl.DrawRectangleLines(screenX, screenY, l.cellSize, l.cellSize, color)
+						return rl.Color{}, false
+					}
+					return rl.ColorAlpha(color, ghostCellAlpha), true
+				}
 			}
 			if cell.IsGhost {
-				return rl.ColorAlpha(cell.Color, ghostCellAlpha), true
+				return rl.ColorAlpha(tetrominoColors[cell.Kind], ghostCellAlpha), true
 			}
 
 			return rl.Color{}, false
@@ -109,13 +214,18 @@ func (gs *gameState) DrawMainBoard() {
 	)
 }
 
-func (gs *gameState) DrawHoldingBoard() {
-	drawBoard(
-		holdingBoardBottomLeftX, holdingBoardBottomLeftY,
+func (l *layout) drawHoldingBoard(gs *engine.GameState) {
+	var holding *engine.Tetromino
+	if gs.HoldingTetromino != engine.Kind_None {
+		holding = gs.PreviewTetromino(gs.HoldingTetromino, tetrominoHoldingX, tetrominoHoldingY)
+	}
+
+	l.drawBoard(
+		l.holdingBoardBottomLeftX, l.holdingBoardBottomLeftY,
 		holdingBoardCellsX, holdingBoardCellsY,
 		func(gridX, gridY, screenX, screenY int32) (color rl.Color, cellFilled bool) {
-			if gs.HoldingTetromino != nil {
-				return gs.HoldingTetromino.IsCell(gridX, gridY)
+			if holding != nil {
+				return drawTetromino(holding, gridX, gridY)
 			}
 
 			return rl.Color{}, false
@@ -123,13 +233,24 @@ func (gs *gameState) DrawHoldingBoard() {
 	)
 }
 
-func (gs *gameState) DrawQueueBoard() {
-	drawBoard(
-		queueBoardBottomX, queueBoardBottomY,
-		queueBoardCellsX, queueBoardCellsY,
+func (l *layout) drawQueueBoard(gs *engine.GameState) {
+	// The next tetromino is drawn at the top of the queue board
+	previews := gs.Previews()
+	queue := make([]*engine.Tetromino, len(previews))
+	for i, kind := range previews {
+		queue[i] = gs.PreviewTetromino(
+			kind,
+			tetrominoQueueX,
+			tetrominoQueueY+int32(len(queue)-1-i)*queueBoardCellsPerKind,
+		)
+	}
+
+	l.drawBoard(
+		l.queueBoardBottomX, l.queueBoardBottomY,
+		queueBoardCellsX, int32(len(queue))*queueBoardCellsPerKind,
 		func(gridX, gridY, screenX, screenY int32) (color rl.Color, cellFilled bool) {
-			for _, tetromino := range gs.TetrominoQueue {
-				if color, isFilled := tetromino.IsCell(gridX, gridY); isFilled {
+			for _, tetromino := range queue {
+				if color, isFilled := drawTetromino(tetromino, gridX, gridY); isFilled {
 					return color, isFilled
 				}
 			}
@@ -138,32 +259,54 @@ func (gs *gameState) DrawQueueBoard() {
 	)
 }
 
-func (gs *gameState) DrawScore() {
-	rl.DrawText(
-		scoreText,
-		scoreTextX, scoreTextY,
-		scoreTextSize,
-		textColor,
-	)
+// drawGarbageMeter draws the garbage waiting to rise in versus as a bar left of the main board,
+// a cell tall for each row. Garbage that's ready is at the bottom.
+func (l *layout) drawGarbageMeter(gs *engine.GameState) {
+	width := l.cellSize / 2
+	x := l.boardBottomLeftX - l.cellSize + (l.cellSize-width)/2
 
-	rl.DrawText(
-		fmt.Sprint(gs.Score),
-		scoreNumberX, scoreNumberY,
-		scoreTextSize,
-		textColor,
-	)
+	rows := int32(0)
+	for _, garbage := range gs.IncomingGarbage() {
+		color := garbageWaitingColor
+		if garbage.IsReady(gs) {
+			color = garbageReadyColor
+		}
 
-	rl.DrawText(
-		levelText,
-		levelTextX, levelTextY,
-		scoreTextSize,
-		textColor,
-	)
+		height := int32(garbage.Rows) * l.cellSize
+		if rows*l.cellSize+height > l.boardSizeY {
+			height = l.boardSizeY - rows*l.cellSize
+		}
+		if height <= 0 {
+			break
+		}
+		drawBorderedRectangle(x, l.boardBottomLeftY-rows*l.cellSize-height, width, height, color, boardOutlineColor)
+		rows += int32(garbage.Rows)
+	}
+}
 
-	rl.DrawText(
-		fmt.Sprint(gs.Level()),
-		levelNumberX, levelNumberY,
-		scoreTextSize,
-		textColor,
-	)
+// scoreLine is a label and its value, drawn beside the main board
+type scoreLine struct {
+	label string
+	value string
+	// valueColor is used instead of textColor, if set
+	valueColor rl.Color
+}
+
+func (l *layout) drawScore(lines []scoreLine) {
+	drawRightAlignedText := func(text string, y int32, color rl.Color) {
+		width := rl.MeasureText(text, scoreTextSize)
+		rl.DrawText(text, l.scoreRightX-width, y, scoreTextSize, color)
+	}
+
+	y := l.scoreTopY
+	for _, line := range lines {
+		valueColor := line.valueColor
+		if valueColor == (rl.Color{}) {
+			valueColor = textColor
+		}
+
+		drawRightAlignedText(line.label, y, textColor)
+		drawRightAlignedText(line.value, y+scoreLineSizeY, valueColor)
+		y += scoreLineSizeY * 2
+	}
 }
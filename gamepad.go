@@ -0,0 +1,258 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+
+	"getris/engine"
+)
+
+// maxGamepads is how many gamepads raylib can have connected at once
+const maxGamepads int32 = 4
+
+// Gamepad buttons, from raylib's GamepadButton.
+// The constants in this version of raylib-go are from an older raylib, so they don't match.
+const (
+	gamepadButton_DpadUp int32 = iota + 1
+	gamepadButton_DpadRight
+	gamepadButton_DpadDown
+	gamepadButton_DpadLeft
+	gamepadButton_FaceUp
+	gamepadButton_FaceRight
+	gamepadButton_FaceDown
+	gamepadButton_FaceLeft
+	gamepadButton_L1
+	gamepadButton_L2
+	gamepadButton_R1
+	gamepadButton_R2
+	gamepadButton_Select
+	gamepadButton_Home
+	gamepadButton_Start
+	gamepadButton_L3
+	gamepadButton_R3
+)
+
+// Gamepad axes, from raylib's GamepadAxis
+const (
+	gamepadAxis_LeftX int32 = iota
+	gamepadAxis_LeftY
+	gamepadAxis_RightX
+	gamepadAxis_RightY
+)
+
+// gamepadControl is a button, or one direction of a stick
+type gamepadControl struct {
+	// button is 0 for sticks
+	button int32
+	axis   int32
+	// sign is the direction along the axis, -1 or 1
+	sign float32
+}
+
+// gamepadControlNames names the controls that can be bound, for the config.
+// Face buttons are named by where they are, since every brand labels them differently.
+var gamepadControlNames = map[string]gamepadControl{
+	"dpad-up":           {button: gamepadButton_DpadUp},
+	"dpad-right":        {button: gamepadButton_DpadRight},
+	"dpad-down":         {button: gamepadButton_DpadDown},
+	"dpad-left":         {button: gamepadButton_DpadLeft},
+	"face-up":           {button: gamepadButton_FaceUp},
+	"face-right":        {button: gamepadButton_FaceRight},
+	"face-down":         {button: gamepadButton_FaceDown},
+	"face-left":         {button: gamepadButton_FaceLeft},
+	"l1":                {button: gamepadButton_L1},
+	"l2":                {button: gamepadButton_L2},
+	"r1":                {button: gamepadButton_R1},
+	"r2":                {button: gamepadButton_R2},
+	"select":            {button: gamepadButton_Select},
+	"home":              {button: gamepadButton_Home},
+	"start":             {button: gamepadButton_Start},
+	"l3":                {button: gamepadButton_L3},
+	"r3":                {button: gamepadButton_R3},
+	"left-stick-up":     {axis: gamepadAxis_LeftY, sign: -1},
+	"left-stick-right":  {axis: gamepadAxis_LeftX, sign: 1},
+	"left-stick-down":   {axis: gamepadAxis_LeftY, sign: 1},
+	"left-stick-left":   {axis: gamepadAxis_LeftX, sign: -1},
+	"right-stick-up":    {axis: gamepadAxis_RightY, sign: -1},
+	"right-stick-right": {axis: gamepadAxis_RightX, sign: 1},
+	"right-stick-down":  {axis: gamepadAxis_RightY, sign: 1},
+	"right-stick-left":  {axis: gamepadAxis_RightX, sign: -1},
+}
+
+func (c gamepadControl) String() string {
+	for name, control := range gamepadControlNames {
+		if control == c {
+			return name
+		}
+	}
+
+	return fmt.Sprintf("button%d", c.button)
+}
+
+func parseGamepadControl(name string) (gamepadControl, error) {
+	if c, ok := gamepadControlNames[name]; ok {
+		return c, nil
+	}
+
+	return gamepadControl{}, fmt.Errorf("unknown gamepad control %q", name)
+}
+
+// gamepadBindings maps each input to the controls that press it
+type gamepadBindings map[engine.Input][]gamepadControl
+
+// defaultGamepadBindings are used for gamepads, until the config changes them
+var defaultGamepadBindings = gamepadBindings{
+	engine.Input_Pause:                  {gamepadControlNames["start"]},
+	engine.Input_Hold:                   {gamepadControlNames["l1"], gamepadControlNames["r1"], gamepadControlNames["face-up"]},
+	engine.Input_RotateCounterClockwise: {gamepadControlNames["face-down"], gamepadControlNames["face-left"]},
+	engine.Input_RotateClockwise:        {gamepadControlNames["face-right"]},
+	engine.Input_HardDrop:               {gamepadControlNames["dpad-up"], gamepadControlNames["left-stick-up"]},
+	engine.Input_SoftDrop:               {gamepadControlNames["dpad-down"], gamepadControlNames["left-stick-down"]},
+	engine.Input_MoveLeft:               {gamepadControlNames["dpad-left"], gamepadControlNames["left-stick-left"]},
+	engine.Input_MoveRight:              {gamepadControlNames["dpad-right"], gamepadControlNames["left-stick-right"]},
+	engine.Input_Undo:                   {gamepadControlNames["l2"]},
+	engine.Input_Redo:                   {gamepadControlNames["r2"]},
+}
+
+// gamepadSettings are shared by every gamepad
+type gamepadSettings struct {
+	// Sticks count as centered until they're moved further than deadzone, from 0 to 1
+	deadzone float32
+	// A stick direction is pressed once it's moved threshold of the way from the deadzone to the edge, from 0 to 1
+	threshold float32
+
+	bindings gamepadBindings
+	// controllers holds bindings for gamepads by their name, which replace bindings for the inputs they have
+	controllers map[string]gamepadBindings
+}
+
+func defaultGamepadSettings() gamepadSettings {
+	return gamepadSettings{
+		deadzone:    0.2,
+		threshold:   0.5,
+		bindings:    defaultGamepadBindings,
+		controllers: map[string]gamepadBindings{},
+	}
+}
+
+// bindingsFor returns the bindings for the gamepad with the given name
+func (gs *gamepadSettings) bindingsFor(name string) gamepadBindings {
+	bindings := gamepadBindings{}
+	for input, controls := range gs.bindings {
+		bindings[input] = controls
+	}
+	for input, controls := range gs.controllers[name] {
+		bindings[input] = controls
+	}
+
+	return bindings
+}
+
+// GamepadSource turns the buttons and sticks of its gamepads into inputs.
+// Gamepads can be plugged in and out while playing.
+type GamepadSource struct {
+	settings *gamepadSettings
+	// ids are the gamepads polled
+	ids  []int32
+	pads [maxGamepads]gamepad
+}
+
+// gamepad is the state of one of the gamepads
+type gamepad struct {
+	isConnected bool
+	name        string
+	bindings    gamepadBindings
+	// held holds the inputs pressed on the last poll
+	held map[engine.Input]bool
+}
+
+// NewGamepadSource polls the gamepads with the given ids, or every gamepad if there are none
+func NewGamepadSource(settings *gamepadSettings, ids ...int32) *GamepadSource {
+	if len(ids) == 0 {
+		for id := int32(0); id < maxGamepads; id++ {
+			ids = append(ids, id)
+		}
+	}
+
+	return &GamepadSource{settings: settings, ids: ids}
+}
+
+func (g *GamepadSource) Poll(events []engine.InputEvent) []engine.InputEvent {
+	for _, id := range g.ids {
+		pad := &g.pads[id]
+
+		if !rl.IsGamepadAvailable(id) {
+			if pad.isConnected {
+				fmt.Fprintf(os.Stderr, "gamepad %d disconnected: %s\n", id, pad.name)
+				// Let go of everything held, so nothing is stuck down
+				events = pad.update(events, func(gamepadControl) bool { return false })
+				pad.isConnected = false
+			}
+			continue
+		}
+
+		if name := rl.GetGamepadName(id); !pad.isConnected || pad.name != name {
+			fmt.Fprintf(os.Stderr, "gamepad %d connected: %s\n", id, name)
+			pad.isConnected = true
+			pad.name = name
+			pad.bindings = g.settings.bindingsFor(name)
+		}
+
+		events = pad.update(events, func(c gamepadControl) bool {
+			return g.isDown(id, c)
+		})
+	}
+
+	return events
+}
+
+// isDown returns true if the control is pressed on the gamepad
+func (g *GamepadSource) isDown(id int32, c gamepadControl) bool {
+	if c.button != 0 {
+		return rl.IsGamepadButtonDown(id, c.button)
+	}
+
+	return g.settings.isPushed(rl.GetGamepadAxisMovement(id, c.axis) * c.sign)
+}
+
+// isPushed returns true if a stick moved this far, from -1 to 1, presses its direction
+func (gs *gamepadSettings) isPushed(movement float32) bool {
+	if movement <= gs.deadzone {
+		return false
+	}
+
+	// The threshold is measured from the edge of the deadzone
+	movement = (movement - gs.deadzone) / (1 - gs.deadzone)
+	return movement >= gs.threshold
+}
+
+// update sends an input down when one of its controls is pressed, and up when all of them are let go
+func (pad *gamepad) update(events []engine.InputEvent, isDown func(gamepadControl) bool) []engine.InputEvent {
+	if pad.held == nil {
+		pad.held = map[engine.Input]bool{}
+	}
+
+	for _, input := range engine.Inputs {
+		isHeld := false
+		for _, c := range pad.bindings[input] {
+			if isDown(c) {
+				isHeld = true
+				break
+			}
+		}
+		if isHeld == pad.held[input] {
+			continue
+		}
+
+		pad.held[input] = isHeld
+		action := engine.Action_Up
+		if isHeld {
+			action = engine.Action_Down
+		}
+		events = append(events, engine.InputEvent{Input: input, Action: action})
+	}
+
+	return events
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"getris/engine"
+)
+
+func TestIsPushed(t *testing.T) {
+	settings := gamepadSettings{deadzone: 0.2, threshold: 0.5}
+
+	tests := []struct {
+		movement float32
+		want     bool
+	}{
+		{movement: -1, want: false},
+		{movement: 0, want: false},
+		{movement: 0.2, want: false},
+		// Halfway from the deadzone to the edge
+		{movement: 0.59, want: false},
+		{movement: 0.6, want: true},
+		{movement: 1, want: true},
+	}
+	for _, tt := range tests {
+		if got := settings.isPushed(tt.movement); got != tt.want {
+			t.Errorf("isPushed(%v) = %v, want %v", tt.movement, got, tt.want)
+		}
+	}
+}
+
+func TestGamepadUpdate(t *testing.T) {
+	a := gamepadControl{button: gamepadButton_FaceDown}
+	b := gamepadControl{button: gamepadButton_FaceLeft}
+	left := gamepadControl{button: gamepadButton_DpadLeft}
+	pad := gamepad{bindings: gamepadBindings{
+		engine.Input_RotateCounterClockwise: {a, b},
+		engine.Input_MoveLeft:               {left},
+	}}
+
+	// Each poll holds these controls down
+	polls := []struct {
+		name string
+		held []gamepadControl
+		want []engine.InputEvent
+	}{
+		{name: "nothing held", want: nil},
+		{name: "press", held: []gamepadControl{a}, want: []engine.InputEvent{down(engine.Input_RotateCounterClockwise)}},
+		// Held inputs don't repeat, the game repeats them itself
+		{name: "still held", held: []gamepadControl{a}, want: nil},
+		{name: "second control for the same input", held: []gamepadControl{a, b}, want: nil},
+		{name: "first control let go", held: []gamepadControl{b}, want: nil},
+		{
+			name: "both inputs",
+			held: []gamepadControl{b, left},
+			want: []engine.InputEvent{down(engine.Input_MoveLeft)},
+		},
+		{
+			name: "all let go",
+			want: []engine.InputEvent{up(engine.Input_RotateCounterClockwise), up(engine.Input_MoveLeft)},
+		},
+		{name: "stays up", want: nil},
+	}
+
+	for _, p := range polls {
+		isDown := func(c gamepadControl) bool {
+			for _, h := range p.held {
+				if h == c {
+					return true
+				}
+			}
+			return false
+		}
+
+		events := pad.update(nil, isDown)
+		if !reflect.DeepEqual(events, p.want) {
+			t.Errorf("%s: update() = %v, want %v", p.name, events, p.want)
+		}
+	}
+}
+
+func TestBindingsFor(t *testing.T) {
+	settings := defaultGamepadSettings()
+	settings.controllers["Arcade Stick"] = gamepadBindings{
+		engine.Input_HardDrop: {gamepadControlNames["face-up"]},
+	}
+
+	bindings := settings.bindingsFor("Arcade Stick")
+	if got, want := bindings[engine.Input_HardDrop], []gamepadControl{gamepadControlNames["face-up"]}; !reflect.DeepEqual(got, want) {
+		t.Errorf("hard drop is bound to %v, want %v", got, want)
+	}
+	if got, want := bindings[engine.Input_MoveLeft], defaultGamepadBindings[engine.Input_MoveLeft]; !reflect.DeepEqual(got, want) {
+		t.Errorf("move left is bound to %v, want the default %v", got, want)
+	}
+
+	// Other gamepads keep the defaults
+	if got := settings.bindingsFor("Pad"); !reflect.DeepEqual(got, defaultGamepadBindings) {
+		t.Errorf("bindings for another gamepad = %v, want the defaults", got)
+	}
+	if got := defaultGamepadBindings[engine.Input_HardDrop]; len(got) != 2 {
+		t.Errorf("the controller's bindings changed the defaults, hard drop is bound to %v", got)
+	}
+}
@@ -2,101 +2,155 @@ package main
 
 import (
 	"fmt"
-	"time"
+	"sort"
 
 	rl "github.com/gen2brain/raylib-go/raylib"
-)
-
-type Input int
-
-const (
-	Input_Pause Input = iota
-	Input_Hold
-	Input_RotateCounterClockwise
-	Input_RotateClockwise
-	Input_HardDrop
-	Input_SoftDrop
-	Input_MoveLeft
-	Input_MoveRight
-)
 
-type Action int
-
-const (
-	Action_Down Action = iota
-	Action_Hold
-	Action_Up
+	"getris/engine"
 )
 
-type InputEvent struct {
-	Input   Input
-	Action  Action
-	KeyCode int32
-}
-
-// KeyMap maps raylib's key codes to game's input codes
-var KeyMap = map[int32]Input{
+// defaultKeyMap maps raylib's key codes to game's input codes, until the config changes them
+var defaultKeyMap = map[int32]engine.Input{
 	// Pause
-	rl.KeyEscape: Input_Pause,
-	rl.KeyF1:     Input_Pause,
+	rl.KeyEscape: engine.Input_Pause,
+	rl.KeyF1:     engine.Input_Pause,
 
 	// Hold
-	rl.KeyLeftShift:  Input_Hold,
-	rl.KeyRightShift: Input_Hold,
-	rl.KeyC:          Input_Hold,
-	rl.KeyKp0:        Input_Hold,
+	rl.KeyLeftShift:  engine.Input_Hold,
+	rl.KeyRightShift: engine.Input_Hold,
+	rl.KeyC:          engine.Input_Hold,
+	rl.KeyKp0:        engine.Input_Hold,
 
 	// Rotate Counter-Clockwise
-	rl.KeyLeftControl:  Input_RotateCounterClockwise,
-	rl.KeyRightControl: Input_RotateCounterClockwise,
-	rl.KeyZ:            Input_RotateCounterClockwise,
-	rl.KeyKp3:          Input_RotateCounterClockwise,
-	rl.KeyKp7:          Input_RotateCounterClockwise,
+	rl.KeyLeftControl:  engine.Input_RotateCounterClockwise,
+	rl.KeyRightControl: engine.Input_RotateCounterClockwise,
+	rl.KeyZ:            engine.Input_RotateCounterClockwise,
+	rl.KeyKp3:          engine.Input_RotateCounterClockwise,
+	rl.KeyKp7:          engine.Input_RotateCounterClockwise,
 
 	// Rotate Clockwise
-	rl.KeyX:   Input_RotateClockwise,
-	rl.KeyUp:  Input_RotateClockwise,
-	rl.KeyKp1: Input_RotateClockwise,
-	rl.KeyKp5: Input_RotateClockwise,
-	rl.KeyKp9: Input_RotateClockwise,
+	rl.KeyX:   engine.Input_RotateClockwise,
+	rl.KeyUp:  engine.Input_RotateClockwise,
+	rl.KeyKp1: engine.Input_RotateClockwise,
+	rl.KeyKp5: engine.Input_RotateClockwise,
+	rl.KeyKp9: engine.Input_RotateClockwise,
 
 	// Hard Drop
-	rl.KeySpace: Input_HardDrop,
-	rl.KeyKp8:   Input_HardDrop,
+	rl.KeySpace: engine.Input_HardDrop,
+	rl.KeyKp8:   engine.Input_HardDrop,
 
 	// Soft Drop
-	rl.KeyDown: Input_SoftDrop,
-	rl.KeyKp2:  Input_SoftDrop,
+	rl.KeyDown: engine.Input_SoftDrop,
+	rl.KeyKp2:  engine.Input_SoftDrop,
 
 	// Move Left
-	rl.KeyLeft: Input_MoveLeft,
-	rl.KeyKp4:  Input_MoveLeft,
+	rl.KeyLeft: engine.Input_MoveLeft,
+	rl.KeyKp4:  engine.Input_MoveLeft,
 
 	// Move Right
-	rl.KeyRight: Input_MoveRight,
-	rl.KeyKp6:   Input_MoveRight,
+	rl.KeyRight: engine.Input_MoveRight,
+	rl.KeyKp6:   engine.Input_MoveRight,
+
+	// Undo and Redo, in zen
+	rl.KeyU: engine.Input_Undo,
+	rl.KeyR: engine.Input_Redo,
 }
 
-// InverseKeyMap maps game's input codes to raylib's key codes
-var InverseKeyMap map[Input][]int32
+// KeyMap maps raylib's key codes to game's input codes
+var KeyMap map[int32]engine.Input
+
+// InverseKeyMap maps game's input codes to raylib's key codes, in order
+var InverseKeyMap map[engine.Input][]int32
 
 func init() {
-	InverseKeyMap = make(map[Input][]int32)
-	for key, input := range KeyMap {
-		InverseKeyMap[input] = append(InverseKeyMap[input], key)
+	setKeyMap(defaultKeyMap)
+}
+
+// defaultVersusKeyMaps are each player's keys in versus, until the config changes them.
+// Player 1 is on the left of the keyboard, and player 2 on the arrows.
+var defaultVersusKeyMaps = [engine.VersusPlayers]map[int32]engine.Input{
+	{
+		rl.KeyEscape:    engine.Input_Pause,
+		rl.KeyLeftShift: engine.Input_Hold,
+		rl.KeyC:         engine.Input_RotateCounterClockwise,
+		rl.KeyV:         engine.Input_RotateClockwise,
+		rl.KeyW:         engine.Input_HardDrop,
+		rl.KeyS:         engine.Input_SoftDrop,
+		rl.KeyA:         engine.Input_MoveLeft,
+		rl.KeyD:         engine.Input_MoveRight,
+	},
+	{
+		rl.KeyEnter:      engine.Input_Pause,
+		rl.KeyRightShift: engine.Input_Hold,
+		rl.KeyPeriod:     engine.Input_RotateCounterClockwise,
+		rl.KeySlash:      engine.Input_RotateClockwise,
+		rl.KeyUp:         engine.Input_HardDrop,
+		rl.KeyDown:       engine.Input_SoftDrop,
+		rl.KeyLeft:       engine.Input_MoveLeft,
+		rl.KeyRight:      engine.Input_MoveRight,
+	},
+}
+
+// setKeyMap replaces KeyMap, and rebuilds InverseKeyMap from it
+func setKeyMap(keyMap map[int32]engine.Input) {
+	KeyMap = keyMap
+	InverseKeyMap = inverseKeyMap(keyMap)
+}
+
+// inverseKeyMap maps each input in keyMap to its keys, in order
+func inverseKeyMap(keyMap map[int32]engine.Input) map[engine.Input][]int32 {
+	inverse := make(map[engine.Input][]int32)
+	for key, input := range keyMap {
+		inverse[input] = append(inverse[input], key)
+	}
+	for _, keys := range inverse {
+		sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
 	}
+
+	return inverse
 }
 
-func InputForwarder(eventChannel chan InputEvent) {
+// InputSource is somewhere inputs come from, like the keyboard or a gamepad.
+// Poll appends the inputs pressed and released since it was last called to events.
+// It's intended to be called from the render loop.
+type InputSource interface {
+	Poll(events []engine.InputEvent) []engine.InputEvent
+}
+
+// InputSources polls each of its sources in order, so they can be used together
+type InputSources []InputSource
+
+func (sources InputSources) Poll(events []engine.InputEvent) []engine.InputEvent {
+	for _, source := range sources {
+		events = source.Poll(events)
+	}
+
+	return events
+}
+
+// KeyboardSource polls raylib for keys, and maps them to inputs with its key map, or KeyMap if it has none
+type KeyboardSource struct {
+	keyMap map[int32]engine.Input
+}
+
+// NewKeyboardSource maps keys with keyMap instead of KeyMap, like for one of the players in versus
+func NewKeyboardSource(keyMap map[int32]engine.Input) KeyboardSource {
+	return KeyboardSource{keyMap: keyMap}
+}
+
+func (k KeyboardSource) Poll(events []engine.InputEvent) []engine.InputEvent {
+	if k.keyMap != nil {
+		return pollKeyMap(k.keyMap, events)
+	}
+
 	keyPressed := rl.GetKeyPressed()
 	for keyPressed != 0 {
 		if input, ok := KeyMap[keyPressed]; ok {
-			eventChannel <- InputEvent{
+			events = append(events, engine.InputEvent{
 				Input:   input,
-				Action:  Action_Down,
+				Action:  engine.Action_Down,
 				KeyCode: keyPressed,
-			}
-			waitForLongPress(eventChannel, keyPressed)
+			})
 		}
 
 		keyPressed = rl.GetKeyPressed()
@@ -105,62 +159,85 @@ func InputForwarder(eventChannel chan InputEvent) {
 	for input, keyCodes := range InverseKeyMap {
 		for _, keyCode := range keyCodes {
 			if rl.IsKeyReleased(keyCode) {
-				eventChannel <- InputEvent{
+				events = append(events, engine.InputEvent{
 					Input:   input,
-					Action:  Action_Up,
+					Action:  engine.Action_Up,
 					KeyCode: keyCode,
-				}
+				})
 			}
 		}
 	}
+
+	return events
 }
 
-func waitForLongPress(eventChannel chan<- InputEvent, keyCode int32) {
-	time.AfterFunc(inputLongPressInterval, func() {
-		if rl.IsKeyDown(keyCode) {
-			eventChannel <- InputEvent{
-				Input:   Input(KeyMap[keyCode]),
-				Action:  Action_Hold,
-				KeyCode: keyCode,
-			}
+// pollKeyMap checks each key in keyMap.
+// Unlike the key queue, which can only be read once per frame, any number of key maps can be checked.
+func pollKeyMap(keyMap map[int32]engine.Input, events []engine.InputEvent) []engine.InputEvent {
+	keys := make([]int32, 0, len(keyMap))
+	for key := range keyMap {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	for _, key := range keys {
+		switch {
+		case rl.IsKeyPressed(key):
+			events = append(events, engine.InputEvent{Input: keyMap[key], Action: engine.Action_Down, KeyCode: key})
+		case rl.IsKeyReleased(key):
+			events = append(events, engine.InputEvent{Input: keyMap[key], Action: engine.Action_Up, KeyCode: key})
 		}
-	})
+	}
+
+	return events
 }
 
-func DebugInputEvent(eventChannel chan InputEvent) {
-	go func() {
-		for e := range eventChannel {
-			var actionText string
-			switch e.Action {
-			case Action_Down:
-				actionText = "down"
-			case Action_Hold:
-				actionText = "hold"
-			case Action_Up:
-				actionText = "up"
-			}
+// PlayerSource marks the inputs from its source as being for one player, in versus
+type PlayerSource struct {
+	Source InputSource
+	Player int
+}
 
-			var inputText string
-			switch e.Input {
-			case Input_Pause:
-				inputText = "pause"
-			case Input_Hold:
-				inputText = "hold"
-			case Input_RotateCounterClockwise:
-				inputText = "rotate counter-clockwise"
-			case Input_RotateClockwise:
-				inputText = "rotate clockwise"
-			case Input_HardDrop:
-				inputText = "hard drop"
-			case Input_SoftDrop:
-				inputText = "soft drop"
-			case Input_MoveLeft:
-				inputText = "move left"
-			case Input_MoveRight:
-				inputText = "move right"
-			}
+func (p PlayerSource) Poll(events []engine.InputEvent) []engine.InputEvent {
+	start := len(events)
+	events = p.Source.Poll(events)
+	for i := start; i < len(events); i++ {
+		events[i].Player = p.Player
+	}
 
-			fmt.Printf("Input: %s, Action: %s, KeyCode: %d\n", inputText, actionText, e.KeyCode)
+	return events
+}
+
+func DebugInputEvent(events []engine.InputEvent) {
+	for _, e := range events {
+		var actionText string
+		switch e.Action {
+		case engine.Action_Down:
+			actionText = "down"
+		case engine.Action_Up:
+			actionText = "up"
+		}
+
+		var inputText string
+		switch e.Input {
+		case engine.Input_Pause:
+			inputText = "pause"
+		case engine.Input_Hold:
+			inputText = "hold"
+		case engine.Input_RotateCounterClockwise:
+			inputText = "rotate counter-clockwise"
+		case engine.Input_RotateClockwise:
+			inputText = "rotate clockwise"
+		case engine.Input_HardDrop:
+			inputText = "hard drop"
+		case engine.Input_SoftDrop:
+			inputText = "soft drop"
+		case engine.Input_MoveLeft:
+			inputText = "move left"
+		case engine.Input_MoveRight:
+			inputText = "move right"
 		}
-	}()
+
+		fmt.Printf("Input: %s, Action: %s, KeyCode: %d\n", inputText, actionText, e.KeyCode)
+	}
 }
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"getris/engine"
+)
+
+// fakeSource is an InputSource that gives its events on every poll
+type fakeSource struct {
+	events []engine.InputEvent
+	polls  int
+}
+
+func (f *fakeSource) Poll(events []engine.InputEvent) []engine.InputEvent {
+	f.polls++
+	return append(events, f.events...)
+}
+
+func down(input engine.Input) engine.InputEvent {
+	return engine.InputEvent{Input: input, Action: engine.Action_Down}
+}
+
+func up(input engine.Input) engine.InputEvent {
+	return engine.InputEvent{Input: input, Action: engine.Action_Up}
+}
+
+func TestInputSourcesPoll(t *testing.T) {
+	keyboard := &fakeSource{events: []engine.InputEvent{down(engine.Input_MoveLeft), up(engine.Input_Hold)}}
+	empty := &fakeSource{}
+	pad := &fakeSource{events: []engine.InputEvent{down(engine.Input_HardDrop)}}
+	sources := InputSources{keyboard, empty, pad}
+
+	// Events already polled are kept, and each source's are appended in order
+	events := sources.Poll([]engine.InputEvent{up(engine.Input_Pause)})
+	want := []engine.InputEvent{
+		up(engine.Input_Pause), down(engine.Input_MoveLeft), up(engine.Input_Hold), down(engine.Input_HardDrop),
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("Poll() = %v, want %v", events, want)
+	}
+	for i, s := range []*fakeSource{keyboard, empty, pad} {
+		if s.polls != 1 {
+			t.Errorf("source %d was polled %d times, want 1", i, s.polls)
+		}
+	}
+
+	if events := (InputSources{}).Poll(nil); len(events) != 0 {
+		t.Errorf("Poll() with no sources = %v, want nothing", events)
+	}
+}
+
+func TestPlayerSource(t *testing.T) {
+	tests := []struct {
+		name    string
+		player  int
+		polled  []engine.InputEvent
+		fromSrc []engine.InputEvent
+	}{
+		{name: "player 1", player: 0, fromSrc: []engine.InputEvent{down(engine.Input_MoveRight)}},
+		{name: "player 2", player: 1, fromSrc: []engine.InputEvent{down(engine.Input_MoveRight), up(engine.Input_MoveRight)}},
+		{
+			name:    "after another player's",
+			player:  1,
+			polled:  []engine.InputEvent{{Input: engine.Input_Hold, Player: 0}},
+			fromSrc: []engine.InputEvent{down(engine.Input_SoftDrop)},
+		},
+		{name: "nothing polled", player: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := PlayerSource{Source: &fakeSource{events: tt.fromSrc}, Player: tt.player}
+			events := source.Poll(append([]engine.InputEvent{}, tt.polled...))
+
+			if len(events) != len(tt.polled)+len(tt.fromSrc) {
+				t.Fatalf("Poll() returned %d events, want %d", len(events), len(tt.polled)+len(tt.fromSrc))
+			}
+			// Events polled before aren't touched
+			for i, e := range tt.polled {
+				if events[i] != e {
+					t.Errorf("Poll() changed event %d before it to %+v, want %+v", i, events[i], e)
+				}
+			}
+			for i, e := range events[len(tt.polled):] {
+				want := tt.fromSrc[i]
+				want.Player = tt.player
+				if e != want {
+					t.Errorf("event %d = %+v, want %+v", i, e, want)
+				}
+			}
+		})
+	}
+}
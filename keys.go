@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// keyNames names the keys that can be bound, for the config and menus.
+// Letters and digits are named by themselves, and aren't listed.
+var keyNames = map[int32]string{
+	rl.KeySpace:        "space",
+	rl.KeyEscape:       "escape",
+	rl.KeyEnter:        "enter",
+	rl.KeyTab:          "tab",
+	rl.KeyBackspace:    "backspace",
+	rl.KeyInsert:       "insert",
+	rl.KeyDelete:       "delete",
+	rl.KeyRight:        "right",
+	rl.KeyLeft:         "left",
+	rl.KeyDown:         "down",
+	rl.KeyUp:           "up",
+	rl.KeyPageUp:       "page-up",
+	rl.KeyPageDown:     "page-down",
+	rl.KeyHome:         "home",
+	rl.KeyEnd:          "end",
+	rl.KeyF1:           "f1",
+	rl.KeyF2:           "f2",
+	rl.KeyF3:           "f3",
+	rl.KeyF4:           "f4",
+	rl.KeyF5:           "f5",
+	rl.KeyF6:           "f6",
+	rl.KeyF7:           "f7",
+	rl.KeyF8:           "f8",
+	rl.KeyF9:           "f9",
+	rl.KeyF10:          "f10",
+	rl.KeyF11:          "f11",
+	rl.KeyF12:          "f12",
+	rl.KeyLeftShift:    "left-shift",
+	rl.KeyLeftControl:  "left-control",
+	rl.KeyLeftAlt:      "left-alt",
+	rl.KeyRightShift:   "right-shift",
+	rl.KeyRightControl: "right-control",
+	rl.KeyRightAlt:     "right-alt",
+	rl.KeyLeftBracket:  "[",
+	rl.KeyBackSlash:    "\\",
+	rl.KeyRightBracket: "]",
+	rl.KeyGrave:        "`",
+	rl.KeyApostrophe:   "'",
+	rl.KeyComma:        ",",
+	rl.KeyMinus:        "-",
+	rl.KeyPeriod:       ".",
+	rl.KeySlash:        "/",
+	rl.KeySemicolon:    ";",
+	rl.KeyEqual:        "=",
+	rl.KeyKp0:          "kp0",
+	rl.KeyKp1:          "kp1",
+	rl.KeyKp2:          "kp2",
+	rl.KeyKp3:          "kp3",
+	rl.KeyKp4:          "kp4",
+	rl.KeyKp5:          "kp5",
+	rl.KeyKp6:          "kp6",
+	rl.KeyKp7:          "kp7",
+	rl.KeyKp8:          "kp8",
+	rl.KeyKp9:          "kp9",
+	rl.KeyKpDecimal:    "kp.",
+	rl.KeyKpDivide:     "kp/",
+	rl.KeyKpMultiply:   "kp*",
+	rl.KeyKpSubtract:   "kp-",
+	rl.KeyKpAdd:        "kp+",
+	rl.KeyKpEnter:      "kp-enter",
+	rl.KeyKpEqual:      "kp=",
+}
+
+// keyName returns the name of a key, as it's written in the config
+func keyName(key int32) string {
+	if (key >= rl.KeyA && key <= rl.KeyZ) || (key >= rl.KeyZero && key <= rl.KeyNine) {
+		return strings.ToLower(string(rune(key)))
+	}
+	if name, ok := keyNames[key]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("key%d", key)
+}
+
+// parseKey is the inverse of keyName
+func parseKey(name string) (int32, error) {
+	if len(name) == 1 {
+		c := int32(strings.ToUpper(name)[0])
+		if (c >= rl.KeyA && c <= rl.KeyZ) || (c >= rl.KeyZero && c <= rl.KeyNine) {
+			return c, nil
+		}
+	}
+	for key, n := range keyNames {
+		if n == name {
+			return key, nil
+		}
+	}
+
+	// Keys without a name are written by their code
+	if code, err := strconv.Atoi(strings.TrimPrefix(name, "key")); err == nil && strings.HasPrefix(name, "key") && code > 0 {
+		return int32(code), nil
+	}
+
+	return 0, fmt.Errorf("unknown key %q", name)
+}
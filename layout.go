@@ -0,0 +1,86 @@
+package main
+
+import (
+	"getris/engine"
+)
+
+// layout is where the boards of a game are drawn, in screen space.
+// The main board is centered on (centerX, 0), so more than one game can be drawn side by side.
+type layout struct {
+	cellSize int32
+	centerX  int32
+
+	// Main board
+	boardSizeX, boardSizeY             int32
+	boardBottomLeftX, boardBottomLeftY int32
+
+	// Holding board, left of the main board and level with its top
+	holdingBoardBottomLeftX, holdingBoardBottomLeftY int32
+
+	// Queue board, right of the main board and level with its bottom
+	queueBoardBottomX, queueBoardBottomY int32
+
+	// Score lines are right aligned with the holding board, below it
+	scoreRightX, scoreTopY int32
+}
+
+func newLayout(cellSize, centerX int32) layout {
+	l := layout{cellSize: cellSize, centerX: centerX}
+
+	l.boardSizeX = cellSize * engine.BoardCellsX
+	l.boardSizeY = cellSize * engine.BoardCellsY_Visible
+	l.boardBottomLeftX = centerX - (l.boardSizeX / 2)
+	l.boardBottomLeftY = l.boardSizeY / 2
+
+	margin := cellSize
+	holdingBoardSizeX := cellSize * holdingBoardCellsX
+	holdingBoardSizeY := cellSize * holdingBoardCellsY
+	l.holdingBoardBottomLeftX = l.boardBottomLeftX - holdingBoardSizeX - margin
+	l.holdingBoardBottomLeftY = l.boardBottomLeftY - (l.boardSizeY - holdingBoardSizeY)
+
+	l.queueBoardBottomX = l.boardBottomLeftX + l.boardSizeX + margin
+	l.queueBoardBottomY = l.boardBottomLeftY
+
+	l.scoreRightX = l.holdingBoardBottomLeftX + holdingBoardSizeX
+	l.scoreTopY = l.holdingBoardBottomLeftY + margin
+
+	return l
+}
+
+// size returns the width and height of the boards, with a cell of margin around them
+func (l *layout) size() (x, y int32) {
+	return 2 * (l.boardSizeX/2 + l.cellSize*(1+queueBoardCellsX+1)), l.boardSizeY + 2*l.cellSize
+}
+
+// screenSize returns the size of the window, big enough for the boards and never smaller than the internal screen
+func (l *layout) screenSize() (x, y int32) {
+	x, y = l.size()
+
+	if x < internalScreenX {
+		x = internalScreenX
+	}
+	if y < internalScreenY {
+		y = internalScreenY
+	}
+
+	return x, y
+}
+
+// versusLayouts returns a layout for each player in versus, side by side, and the size of the window for them
+func versusLayouts(cellSize int32) (layouts [engine.VersusPlayers]layout, screenX, screenY int32) {
+	single := newLayout(cellSize, 0)
+	width, _ := single.size()
+	for i := range layouts {
+		centerX := width*int32(i) + width/2 - width*int32(len(layouts))/2
+		layouts[i] = newLayout(cellSize, centerX)
+	}
+
+	screenX, screenY = layouts[0].screenSize()
+	if x := width * int32(len(layouts)); x > screenX {
+		screenX = x
+	}
+	return layouts, screenX, screenY
+}
+
+// gameLayout is used to draw every game, its cell size comes from the config
+var gameLayout = newLayout(defaultCellSize, 0)
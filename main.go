@@ -1,13 +1,58 @@
 package main
 
 import (
+	"fmt"
+	"os"
+
 	rl "github.com/gen2brain/raylib-go/raylib"
+
+	"getris/engine"
+	"getris/spectate"
 )
 
 func main() {
+	// Subcommands parse their own flags
+	if len(os.Args) > 1 && os.Args[1] == "watch" {
+		os.Exit(watch(os.Args[2:]))
+	}
+
+	s := parseFlags()
+
+	var server *spectate.Server
+	if s.spectatePort != 0 {
+		var err error
+		server, err = spectate.Listen(fmt.Sprintf(":%d", s.spectatePort))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "couldn't stream to watchers:", err)
+			os.Exit(2)
+		}
+		defer server.Close()
+		fmt.Fprintln(os.Stderr, "streaming to watchers on", server.Addr())
+	}
+
+	runWindow(server, func() screen {
+		var current screen = newTitleScreen(s)
+		switch {
+		case s.replay != nil:
+			current = newPlaybackScreen(s, s.replay, current)
+		case s.startHosting:
+			current = newHostScreen(s, current)
+		case s.startJoining:
+			js := newJoinScreen(s, current)
+			js.startJoining()
+			current = js
+		}
+		return current
+	})
+}
+
+// runWindow opens the window, and shows screens from the one start returns until the window is closed.
+// The games on screen are published to server, if it isn't nil.
+func runWindow(server *spectate.Server, start func() screen) {
+	screenX, screenY := gameLayout.screenSize()
 	rl.InitWindow(
-		internalScreenX,
-		internalScreenY,
+		screenX,
+		screenY,
 		"Getris",
 	)
 
@@ -16,26 +61,35 @@ func main() {
 
 	// Camera puts (0, 0) at the center of the screen
 	camera := rl.NewCamera2D(
-		rl.NewVector2(float32(internalScreenX/2), float32(internalScreenY/2)),
+		rl.NewVector2(float32(screenX/2), float32(screenY/2)),
 		rl.NewVector2(0.0, 0.0),
 		0.0, 1.0,
 	)
 
-	inputEventChannel := make(chan InputEvent)
-	defer close(inputEventChannel)
+	current := start()
 
-	game := newGameState()
-	game.Run(inputEventChannel)
+	rl.SetTargetFPS(int32(engine.FramesPerSecond))
+	for (!rl.WindowShouldClose()) && (current != nil) {
+		current = current.Update()
+		if current == nil {
+			break
+		}
+		if server != nil {
+			server.Publish(spectatedStateOf(current))
+		}
 
-	rl.SetTargetFPS(60)
-	for (!rl.WindowShouldClose()) && (!game.IsDone) {
-		InputForwarder(inputEventChannel)
+		// Resize the window for the screen, keeping (0, 0) at the center
+		if x, y := screenSizeOf(current); x != screenX || y != screenY {
+			screenX, screenY = x, y
+			rl.SetWindowSize(int(screenX), int(screenY))
+			camera.Offset = rl.NewVector2(float32(screenX/2), float32(screenY/2))
+		}
 
 		rl.BeginDrawing()
 		rl.ClearBackground(backgroundColor)
 		rl.BeginMode2D(camera)
 
-		game.Draw()
+		current.Draw()
 
 		rl.EndMode2D()
 		rl.EndDrawing()
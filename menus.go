@@ -0,0 +1,434 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+
+	"getris/engine"
+	"getris/records"
+	"getris/replay"
+)
+
+//// Title
+
+type titleScreen struct {
+	menu menu
+}
+
+func newTitleScreen(s *settings) *titleScreen {
+	ts := &titleScreen{}
+	ts.menu = menu{
+		title: titleText,
+		items: []menuItem{
+			{label: "Play", selected: func() screen { return newModeSelectScreen(s) }},
+			{label: "Records", selected: func() screen { return newRecordsScreen(s) }},
+			{label: "Replays", selected: func() screen { return newReplaysScreen(s) }},
+			{label: "Settings", selected: func() screen { return newSettingsScreen(s, ts) }},
+			{label: "Quit", selected: func() screen { return nil }},
+		},
+	}
+
+	return ts
+}
+
+func (ts *titleScreen) Update() screen {
+	if next, ok := ts.menu.update(); ok {
+		return next
+	}
+
+	return ts
+}
+
+func (ts *titleScreen) Draw() {
+	ts.menu.draw(menuTopY)
+}
+
+//// Mode select
+
+type modeSelectScreen struct {
+	menu     menu
+	settings *settings
+}
+
+func newModeSelectScreen(s *settings) *modeSelectScreen {
+	ms := &modeSelectScreen{settings: s}
+	ms.menu = menu{
+		title: modeSelectText,
+		items: []menuItem{
+			{label: "Marathon", selected: func() screen { return newMarathonScreen(s) }},
+			{
+				label: "Sprint",
+				value: func() string { return fmt.Sprintf("%d lines", s.sprintLines) },
+				change: func(delta int) {
+					s.sprintLines = sprintLineGoals[cycle(indexOf(sprintLineGoals[:], s.sprintLines), delta, len(sprintLineGoals))]
+				},
+				selected: func() screen { return newPlayScreen(s, engine.SprintMode{Lines: s.sprintLines}) },
+			},
+			{
+				label: "Ultra",
+				value: func() string { return fmt.Sprintf("%d min", s.ultraMinutes) },
+				change: func(delta int) {
+					s.ultraMinutes = ultraMinuteLimits[cycle(indexOf(ultraMinuteLimits[:], s.ultraMinutes), delta, len(ultraMinuteLimits))]
+				},
+				selected: func() screen {
+					return newPlayScreen(s, engine.UltraMode{TimeLimit: time.Duration(s.ultraMinutes) * time.Minute})
+				},
+			},
+			{label: "Dig", selected: func() screen { return newDigScreen(s) }},
+			{label: "Master", selected: func() screen { return newPlayScreen(s, engine.MasterMode{}) }},
+			{
+				label: "Zen",
+				value: func() string {
+					if s.zen.Gravity {
+						return "gravity"
+					}
+					return "no gravity"
+				},
+				change:   func(delta int) { s.zen.Gravity = !s.zen.Gravity },
+				selected: func() screen { return newPlayScreen(s, s.zen) },
+			},
+			{label: "Versus", selected: func() screen { return newVersusScreen(s, [engine.VersusPlayers]int{}) }},
+			{label: "Host online", selected: func() screen { return newHostScreen(s, ms) }},
+			{label: "Join online", selected: func() screen { return newJoinScreen(s, ms) }},
+		},
+	}
+
+	return ms
+}
+
+func (ms *modeSelectScreen) Update() screen {
+	if rl.IsKeyPressed(rl.KeyEscape) {
+		return newTitleScreen(ms.settings)
+	}
+	if next, ok := ms.menu.update(); ok {
+		return next
+	}
+
+	return ms
+}
+
+func (ms *modeSelectScreen) Draw() {
+	ms.menu.draw(menuTopY)
+}
+
+//// Mode options
+
+// modeOptionsScreen changes the options of a mode, before starting it
+type modeOptionsScreen struct {
+	menu     menu
+	settings *settings
+}
+
+// newModeOptionsScreen shows the options, between a start and back item
+func newModeOptionsScreen(s *settings, title string, start func() engine.Mode, options []menuItem) *modeOptionsScreen {
+	items := []menuItem{{label: "Start", selected: func() screen { return newPlayScreen(s, start()) }}}
+	items = append(items, options...)
+	items = append(items, menuItem{label: "Back", selected: func() screen { return newModeSelectScreen(s) }})
+
+	return &modeOptionsScreen{
+		settings: s,
+		menu:     menu{title: title, items: items},
+	}
+}
+
+func (ms *modeOptionsScreen) Update() screen {
+	if rl.IsKeyPressed(rl.KeyEscape) {
+		return newModeSelectScreen(ms.settings)
+	}
+	if next, ok := ms.menu.update(); ok {
+		return next
+	}
+
+	return ms
+}
+
+func (ms *modeOptionsScreen) Draw() {
+	ms.menu.draw(menuTopY)
+}
+
+func newMarathonScreen(s *settings) *modeOptionsScreen {
+	m := &s.marathon
+	return newModeOptionsScreen(s, marathonText, func() engine.Mode { return s.marathon }, []menuItem{
+		{
+			label: "Start level",
+			value: func() string { return fmt.Sprint(m.StartLevel) },
+			change: func(delta int) {
+				// A game can't start past the level it ends on
+				maxLevel := engine.MarathonMaxLevel
+				if m.EndLevel > 0 {
+					maxLevel = m.EndLevel
+				}
+				m.StartLevel = cycle(m.StartLevel-1, delta, maxLevel) + 1
+			},
+		},
+		{
+			label: "Goal",
+			value: func() string { return m.Goal.String() },
+			change: func(delta int) {
+				m.Goal = engine.MarathonGoalKinds[cycle(int(m.Goal), delta, len(engine.MarathonGoalKinds))]
+			},
+		},
+		{
+			label: "Length",
+			value: func() string {
+				if m.EndLevel == 0 {
+					return "endless"
+				}
+				return fmt.Sprintf("%d levels", m.EndLevel)
+			},
+			change: func(delta int) {
+				if m.EndLevel == 0 {
+					m.EndLevel = engine.DefaultMarathonMode().EndLevel
+					if m.StartLevel > m.EndLevel {
+						m.EndLevel = engine.MarathonMaxLevel
+					}
+				} else {
+					m.EndLevel = 0
+				}
+			},
+		},
+	})
+}
+
+func newDigScreen(s *settings) *modeOptionsScreen {
+	m := &s.dig
+	return newModeOptionsScreen(s, digText, func() engine.Mode { return s.dig }, []menuItem{
+		{
+			label: "Rows",
+			value: func() string { return fmt.Sprint(m.Rows) },
+			change: func(delta int) {
+				m.Rows = digRowGoals[cycle(indexOf(digRowGoals[:], m.Rows), delta, len(digRowGoals))]
+			},
+		},
+		{
+			label: "Height",
+			value: func() string { return fmt.Sprint(m.Height) },
+			change: func(delta int) {
+				m.Height = digHeights[cycle(indexOf(digHeights[:], m.Height), delta, len(digHeights))]
+			},
+		},
+		{
+			label: "Messiness",
+			value: func() string { return fmt.Sprintf("%d%%", percent(m.Messiness)) },
+			change: func(delta int) {
+				m.Messiness = float64(digMessinessPercents[cycle(indexOf(digMessinessPercents[:], percent(m.Messiness)), delta, len(digMessinessPercents))]) / 100
+			},
+		},
+	})
+}
+
+// percent converts a chance from 0 to 1 to the nearest percent
+func percent(chance float64) int {
+	return int(math.Round(chance * 100))
+}
+
+//// Settings
+
+type settingsScreen struct {
+	menu menu
+	back screen
+}
+
+func newSettingsScreen(s *settings, back screen) *settingsScreen {
+	ss := &settingsScreen{back: back}
+	ss.menu = menu{
+		title: settingsText,
+		items: []menuItem{
+			{
+				label: "Ghost",
+				value: func() string { return drawGhostStyle.String() },
+				change: func(delta int) {
+					drawGhostStyle = ghostStyle(cycle(int(drawGhostStyle), delta, len(ghostStyleNames)))
+				},
+			},
+			{
+				label: "Rotation",
+				value: func() string { return s.rules.RotationSystem.String() },
+				change: func(delta int) {
+					s.rules.RotationSystem = engine.RotationSystemKinds[cycle(int(s.rules.RotationSystem), delta, len(engine.RotationSystemKinds))]
+				},
+			},
+			{
+				label: "Randomizer",
+				value: func() string { return s.rules.Randomizer.String() },
+				change: func(delta int) {
+					s.rules.Randomizer = engine.RandomizerKinds[cycle(int(s.rules.Randomizer), delta, len(engine.RandomizerKinds))]
+				},
+			},
+			{
+				label: "Lock reset",
+				value: func() string { return s.rules.LockReset.String() },
+				change: func(delta int) {
+					s.rules.LockReset = engine.LockResetKinds[cycle(int(s.rules.LockReset), delta, len(engine.LockResetKinds))]
+				},
+			},
+			{
+				label: "Scoring",
+				value: func() string { return s.rules.Scoring.String() },
+				change: func(delta int) {
+					s.rules.Scoring = engine.ScoringKinds[cycle(int(s.rules.Scoring), delta, len(engine.ScoringKinds))]
+				},
+			},
+			{label: "Controls", selected: func() screen { return newBindingsScreen(s, ss) }},
+			{label: "Back", selected: func() screen { return ss.back }},
+		},
+	}
+
+	return ss
+}
+
+func (ss *settingsScreen) Update() screen {
+	if rl.IsKeyPressed(rl.KeyEscape) {
+		return ss.back
+	}
+	if next, ok := ss.menu.update(); ok {
+		return next
+	}
+
+	return ss
+}
+
+func (ss *settingsScreen) Draw() {
+	ss.menu.draw(menuTopY)
+}
+
+//// Game over
+
+type gameOverScreen struct {
+	menu    menu
+	summary []string
+
+	// table is the mode's table in the records, nil if it has none
+	table *records.Table
+	// place is where the game was kept in table, or -1 if it wasn't
+	place int
+}
+
+// newGameOverScreen shows how the game went, and lets its replay be saved.
+// place is where the game was kept in the records, or -1 if it wasn't.
+func newGameOverScreen(s *settings, r *replay.Replay, game *engine.GameState, place int) *gameOverScreen {
+	mode := r.Mode
+	title := gameOverText
+	if mode.IsFinished(game) {
+		title = finishedText
+	}
+
+	gos := &gameOverScreen{
+		summary: gameSummary(mode, game, place),
+		place:   place,
+	}
+	if key, _, _, ok := recordTable(mode); ok {
+		gos.table = s.records.Table(key)
+	}
+
+	// Games in the records saved their replay already
+	saved := ""
+	if gos.table != nil && place >= 0 && gos.table.Entries[place].Replay != "" {
+		saved = r.FileName()
+	}
+	gos.menu = menu{
+		title: title,
+		items: []menuItem{
+			{label: "Retry", selected: func() screen { return newPlayScreen(s, mode) }},
+			{
+				label: "Save replay",
+				value: func() string { return saved },
+				selected: func() screen {
+					if saved == "" {
+						saved = r.FileName()
+						if err := saveReplay(r); err != nil {
+							saved = "failed"
+						}
+					}
+					return gos
+				},
+			},
+			{label: "Menu", selected: func() screen { return newTitleScreen(s) }},
+		},
+	}
+
+	return gos
+}
+
+// saveReplay saves the replay in the replays directory, as r.FileName()
+func saveReplay(r *replay.Replay) error {
+	dir, err := replay.DefaultDir()
+	if err == nil {
+		err = r.Save(filepath.Join(dir, r.FileName()))
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "couldn't save replay:", err)
+	}
+
+	return err
+}
+
+// gameSummary returns the lines describing how a game went, for its mode
+func gameSummary(mode engine.Mode, game *engine.GameState, place int) []string {
+	switch mode := mode.(type) {
+	case engine.SprintMode:
+		summary := []string{
+			fmt.Sprintf("%s %s", timeText, formatTime(game.Time())),
+			fmt.Sprintf("%s %d/%d", linesText, game.LinesCleared(), mode.Lines),
+		}
+		if place == 0 {
+			summary = append(summary, newBestText)
+		}
+
+		return summary
+	case engine.DigMode:
+		return []string{
+			fmt.Sprintf("%s %s", timeText, formatTime(game.Time())),
+			fmt.Sprintf("%s %d/%d", garbageText, mode.Rows-mode.GarbageLeft(game), mode.Rows),
+		}
+	case engine.MasterMode:
+		return []string{
+			fmt.Sprintf("%s %s  %s %d", gradeText, mode.Grade(game), levelText, game.Level()),
+			fmt.Sprintf("%s %s", timeText, formatTime(game.Time())),
+			fmt.Sprintf("%s %d", scoreText, game.Score),
+		}
+	case engine.UltraMode:
+		return []string{
+			fmt.Sprintf("%s %d", scoreText, game.Score),
+			fmt.Sprintf("%s %d", linesText, game.LinesCleared()),
+		}
+	}
+
+	return []string{
+		fmt.Sprintf("%s %d  %s %d", scoreText, game.Score, levelText, game.Level()),
+		fmt.Sprintf("%s %d", linesText, game.LinesCleared()),
+		fmt.Sprintf("%s %s", timeText, formatTime(game.Time())),
+	}
+}
+
+func (gos *gameOverScreen) Update() screen {
+	if next, ok := gos.menu.update(); ok {
+		return next
+	}
+
+	return gos
+}
+
+func (gos *gameOverScreen) Draw() {
+	gos.menu.draw(menuTopY)
+
+	y := menuTopY + menuTitleTextSize + menuLineSpacing + int32(len(gos.menu.items))*(menuTextSize+menuLineSpacing) + menuLineSpacing
+	for _, line := range gos.summary {
+		drawCenteredTextLine(line, y, menuTextSize, textColor)
+		y += menuTextSize + menuLineSpacing
+	}
+
+	if gos.table != nil {
+		// Scroll the table so the new entry is shown
+		first := 0
+		if gos.place >= recordRowsShown {
+			first = gos.place - recordRowsShown + 1
+		}
+		drawRecordTable(gos.table, first, recordRowsShown, gos.place, y+menuLineSpacing)
+	}
+}
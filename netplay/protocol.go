@@ -0,0 +1,221 @@
+// Package netplay plays a versus match between two getris instances over TCP.
+// Games are deterministic, so only inputs are sent: both sides run the same match in lockstep,
+// and a frame is only stepped once both players' inputs for it have arrived.
+package netplay
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"getris/engine"
+)
+
+// The wire protocol is:
+//
+// Handshake. The joiner connects to the host, then each side writes:
+//   - magic, the 4 bytes "GTNP"
+//   - its protocol version, as a uvarint
+//
+// The joiner writes a hello. If the versions are the same, the host answers with a welcome.
+// Otherwise, or if it can't play, it answers with a reject and closes the connection.
+//
+// Messages. Everything after the magic and version is a message:
+//   - the type, as a byte
+//   - the length of the payload, as a uvarint, then the payload
+//
+// The messages are:
+//   - hello (1), joiner to host: JSON with the joiner's handling.
+//   - welcome (2), host to joiner: JSON with the seed, rules, versus mode, the host's handling and the input delay.
+//     The host is player 0 and the joiner player 1.
+//   - reject (3): the reason, as text.
+//   - inputs (4): a frame as a uvarint, the number of inputs as a uvarint, then each input and action as uvarints.
+//     Sent by each side for every frame, even without inputs, Delay frames before it's played. Delay is at least 1.
+//     Inputs and actions are engine.Input and engine.Action, anything else ends the match.
+//     The first Delay frames have no inputs, and aren't sent.
+//   - check (5): a frame as a uvarint, then the hash of the match after it was stepped, as 8 bytes little endian.
+//     Sent by each side every CheckInterval frames, so a desync is noticed.
+//   - bye (6): no payload. The player left, and the connection is about to close.
+
+// Version is the protocol version. Both sides must have the same one to play.
+const Version = 2
+
+var magic = []byte("GTNP")
+
+type messageType byte
+
+const (
+	message_Hello messageType = iota + 1
+	message_Welcome
+	message_Reject
+	message_Inputs
+	message_Check
+	message_Bye
+)
+
+// maxPayload is the largest payload read, so a bad peer can't make us allocate anything bigger
+const maxPayload = 1 << 16
+
+type message struct {
+	kind    messageType
+	payload []byte
+}
+
+// hello is sent by the joiner
+type hello struct {
+	Handling engine.Handling `json:"handling"`
+}
+
+// welcome is sent by the host, with everything needed to start the same match on both sides
+type welcome struct {
+	Seed     int64             `json:"seed"`
+	Rules    engine.Rules      `json:"rules"`
+	Mode     engine.VersusMode `json:"mode"`
+	Handling engine.Handling   `json:"handling"`
+	Delay    int               `json:"delay"`
+}
+
+// writePreamble writes the magic and version
+func writePreamble(w io.Writer) error {
+	buf := make([]byte, len(magic)+binary.MaxVarintLen64)
+	copy(buf, magic)
+	n := binary.PutUvarint(buf[len(magic):], Version)
+	_, err := w.Write(buf[:len(magic)+n])
+	return err
+}
+
+// readPreamble reads the magic, and returns the version
+func readPreamble(r *bufio.Reader) (int, error) {
+	m := make([]byte, len(magic))
+	if _, err := io.ReadFull(r, m); err != nil {
+		return 0, err
+	}
+	if !bytes.Equal(m, magic) {
+		return 0, errors.New("not a getris netplay connection")
+	}
+
+	version, err := binary.ReadUvarint(r)
+	return int(version), err
+}
+
+func writeMessage(w io.Writer, kind messageType, payload []byte) error {
+	buf := make([]byte, 1+binary.MaxVarintLen64, 1+binary.MaxVarintLen64+len(payload))
+	buf[0] = byte(kind)
+	n := binary.PutUvarint(buf[1:], uint64(len(payload)))
+	buf = append(buf[:1+n], payload...)
+
+	_, err := w.Write(buf)
+	return err
+}
+
+func writeJSON(w io.Writer, kind messageType, v interface{}) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return writeMessage(w, kind, payload)
+}
+
+func readMessage(r *bufio.Reader) (message, error) {
+	kind, err := r.ReadByte()
+	if err != nil {
+		return message{}, err
+	}
+	length, err := binary.ReadUvarint(r)
+	if err != nil {
+		return message{}, err
+	}
+	if length > maxPayload {
+		return message{}, fmt.Errorf("message of %d bytes is too big", length)
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return message{}, err
+	}
+	return message{kind: messageType(kind), payload: payload}, nil
+}
+
+// encodeInputs encodes the inputs for a frame
+func encodeInputs(frame int, inputs []engine.InputEvent) []byte {
+	var payload []byte
+	buf := make([]byte, binary.MaxVarintLen64)
+	writeUvarint := func(v uint64) {
+		n := binary.PutUvarint(buf, v)
+		payload = append(payload, buf[:n]...)
+	}
+
+	writeUvarint(uint64(frame))
+	writeUvarint(uint64(len(inputs)))
+	for _, e := range inputs {
+		writeUvarint(uint64(e.Input))
+		writeUvarint(uint64(e.Action))
+	}
+
+	return payload
+}
+
+// decodeInputs decodes the inputs for a frame, for the given player
+func decodeInputs(payload []byte, player int) (frame int, inputs []engine.InputEvent, err error) {
+	r := bytes.NewReader(payload)
+	fields := []uint64{0, 0}
+	for i := range fields {
+		if fields[i], err = binary.ReadUvarint(r); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	frame = int(fields[0])
+	inputs = []engine.InputEvent{}
+	for i := uint64(0); i < fields[1]; i++ {
+		input, err := binary.ReadUvarint(r)
+		if err != nil {
+			return 0, nil, err
+		}
+		action, err := binary.ReadUvarint(r)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		if input >= uint64(len(engine.Inputs)) {
+			return 0, nil, fmt.Errorf("unknown input %d", input)
+		}
+		if action > uint64(engine.Action_Up) {
+			return 0, nil, fmt.Errorf("unknown action %d", action)
+		}
+
+		inputs = append(inputs, engine.InputEvent{
+			Input:  engine.Input(input),
+			Action: engine.Action(action),
+			Player: player,
+		})
+	}
+
+	return frame, inputs, nil
+}
+
+func encodeCheck(frame int, hash uint64) []byte {
+	payload := make([]byte, binary.MaxVarintLen64+8)
+	n := binary.PutUvarint(payload, uint64(frame))
+	binary.LittleEndian.PutUint64(payload[n:], hash)
+	return payload[:n+8]
+}
+
+func decodeCheck(payload []byte) (frame int, hash uint64, err error) {
+	r := bytes.NewReader(payload)
+	f, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var h [8]byte
+	if _, err := io.ReadFull(r, h[:]); err != nil {
+		return 0, 0, err
+	}
+	return int(f), binary.LittleEndian.Uint64(h[:]), nil
+}
@@ -0,0 +1,478 @@
+package netplay
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"time"
+
+	"getris/engine"
+)
+
+const (
+	// DefaultPort is where matches are hosted, unless another port is given
+	DefaultPort = "7777"
+	// DefaultDelay is the default number of frames local inputs wait before they're played,
+	// so they have time to reach the other player
+	DefaultDelay = 4
+	// MinDelay is the shortest delay. Each side sends its inputs for a frame once it has the other side's for
+	// the frame Delay before, so without a delay neither side would ever send.
+	MinDelay = 1
+	// Timeout is how long the other player can go quiet before the connection is given up on
+	Timeout = 10 * time.Second
+	// CheckInterval is the number of frames between checks that both sides are playing the same match
+	CheckInterval = 60
+)
+
+var (
+	// ErrLeft is returned once the other player has left the match
+	ErrLeft = errors.New("the other player left")
+	// ErrDesync is returned when the match has played out differently on each side
+	ErrDesync = errors.New("the match is out of sync")
+)
+
+// Options are decided by the host, and played by both players
+type Options struct {
+	Seed  int64
+	Rules engine.Rules
+	Mode  engine.VersusMode
+	// Delay is the number of frames inputs wait before they're played, at least MinDelay
+	Delay int
+}
+
+// Session is a versus match played with someone over a connection.
+// Each side steps its own copy of the match, with the inputs of both players.
+// Like the match, it isn't safe to share between goroutines.
+type Session struct {
+	Match *engine.Versus
+	// Local is the player on this side, the host is 0 and the joiner is 1
+	Local int
+	Delay int
+	// MaxLag is how far the match can fall behind the clock while waiting for the other player.
+	// Time it's behind by beyond that is skipped. 0 never skips.
+	MaxLag time.Duration
+
+	conn     net.Conn
+	w        *bufio.Writer
+	received chan received
+	done     chan struct{}
+	isClosed bool
+
+	// frame is the next frame to step
+	frame int
+	// inputs holds each player's inputs by the frame they're played on
+	inputs [engine.VersusPlayers]map[int][]engine.InputEvent
+	// pending holds local inputs since the last step, to be played Delay frames after it
+	pending []engine.InputEvent
+	stepped []engine.InputEvent
+
+	// clock is the time passed, and steppedTime is the time the match has been stepped up to
+	clock       time.Duration
+	steppedTime time.Duration
+	isWaiting   bool
+
+	// localChecks and remoteChecks hold the hashes by frame, until the other side's arrives
+	localChecks, remoteChecks map[int]uint64
+
+	// hasLeft is true once the other player has said bye
+	hasLeft bool
+	// err is why the connection was lost
+	err error
+}
+
+// received is a message from the other side, or why none will come
+type received struct {
+	message
+	err error
+}
+
+// Accept waits for a player to join on the listener, and starts a match with them.
+// The host is player 0.
+func Accept(ln net.Listener, opts Options, handling engine.Handling) (*Session, error) {
+	if opts.Delay < MinDelay {
+		return nil, fmt.Errorf("a delay of %d frames is below the minimum of %d", opts.Delay, MinDelay)
+	}
+
+	conn, err := ln.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := acceptHandshake(conn, opts, handling)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("%s: %w", conn.RemoteAddr(), err)
+	}
+
+	return s, nil
+}
+
+func acceptHandshake(conn net.Conn, opts Options, handling engine.Handling) (*Session, error) {
+	conn.SetDeadline(time.Now().Add(Timeout))
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+
+	version, err := readPreamble(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := writePreamble(w); err != nil {
+		return nil, err
+	}
+	if version != Version {
+		reason := fmt.Sprintf("the host plays version %d of the protocol, the joiner version %d", Version, version)
+		writeMessage(w, message_Reject, []byte(reason))
+		w.Flush()
+		return nil, errors.New(reason)
+	}
+
+	m, err := readMessage(r)
+	if err != nil {
+		return nil, err
+	}
+	if m.kind != message_Hello {
+		return nil, fmt.Errorf("expected a hello, got message %d", m.kind)
+	}
+	var h hello
+	if err := json.Unmarshal(m.payload, &h); err != nil {
+		return nil, fmt.Errorf("reading hello: %w", err)
+	}
+
+	err = writeJSON(w, message_Welcome, welcome{
+		Seed:     opts.Seed,
+		Rules:    opts.Rules,
+		Mode:     opts.Mode,
+		Handling: handling,
+		Delay:    opts.Delay,
+	})
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	conn.SetDeadline(time.Time{})
+	return newSession(conn, r, w, 0, opts, [engine.VersusPlayers]engine.Handling{handling, h.Handling}), nil
+}
+
+// Join connects to a host, and starts the match it offers. The joiner is player 1.
+func Join(addr string, handling engine.Handling, timeout time.Duration) (*Session, error) {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := joinHandshake(conn, handling, timeout)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func joinHandshake(conn net.Conn, handling engine.Handling, timeout time.Duration) (*Session, error) {
+	conn.SetDeadline(time.Now().Add(timeout))
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+
+	err := writePreamble(w)
+	if err == nil {
+		err = writeJSON(w, message_Hello, hello{Handling: handling})
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := readPreamble(r)
+	if err != nil {
+		return nil, err
+	}
+	m, err := readMessage(r)
+	if err != nil {
+		if version != Version {
+			return nil, fmt.Errorf("the host plays version %d of the protocol, this is version %d", version, Version)
+		}
+		return nil, err
+	}
+
+	switch m.kind {
+	case message_Reject:
+		return nil, fmt.Errorf("the host refused: %s", m.payload)
+	case message_Welcome:
+	default:
+		return nil, fmt.Errorf("expected a welcome, got message %d", m.kind)
+	}
+
+	var wel welcome
+	if err := json.Unmarshal(m.payload, &wel); err != nil {
+		return nil, fmt.Errorf("reading welcome: %w", err)
+	}
+	if wel.Delay < MinDelay {
+		return nil, fmt.Errorf("the host sent a delay of %d frames, below the minimum of %d", wel.Delay, MinDelay)
+	}
+	if err := wel.Rules.Check(); err != nil {
+		return nil, fmt.Errorf("the host sent rules that can't be played: %w", err)
+	}
+	if err := wel.Mode.Check(); err != nil {
+		return nil, fmt.Errorf("the host sent a mode that can't be played: %w", err)
+	}
+
+	conn.SetDeadline(time.Time{})
+	opts := Options{Seed: wel.Seed, Rules: wel.Rules, Mode: wel.Mode, Delay: wel.Delay}
+	return newSession(conn, r, w, 1, opts, [engine.VersusPlayers]engine.Handling{wel.Handling, handling}), nil
+}
+
+func newSession(conn net.Conn, r *bufio.Reader, w *bufio.Writer, local int, opts Options, handling [engine.VersusPlayers]engine.Handling) *Session {
+	s := &Session{
+		Match:        engine.NewVersus(opts.Rules, handling, opts.Mode, opts.Seed),
+		Local:        local,
+		Delay:        opts.Delay,
+		conn:         conn,
+		w:            w,
+		received:     make(chan received, 256),
+		done:         make(chan struct{}),
+		localChecks:  map[int]uint64{},
+		remoteChecks: map[int]uint64{},
+	}
+
+	// Nobody has pressed anything in the first frames, before the first inputs can arrive
+	for i := range s.inputs {
+		s.inputs[i] = map[int][]engine.InputEvent{}
+		for frame := 0; frame < s.Delay; frame++ {
+			s.inputs[i][frame] = nil
+		}
+	}
+
+	go s.read(r)
+	return s
+}
+
+// read passes on messages from the other side, until the connection is lost or closed
+func (s *Session) read(r *bufio.Reader) {
+	for {
+		s.conn.SetReadDeadline(time.Now().Add(Timeout))
+		m, err := readMessage(r)
+
+		select {
+		case s.received <- received{message: m, err: err}:
+		case <-s.done:
+			return
+		}
+		if err != nil || m.kind == message_Bye {
+			return
+		}
+	}
+}
+
+// Remote returns the player on the other side
+func (s *Session) Remote() int {
+	return 1 - s.Local
+}
+
+// IsWaiting returns true while the match is held up waiting for the other player's inputs
+func (s *Session) IsWaiting() bool {
+	return s.isWaiting
+}
+
+// Update moves the clock on by elapsed, and steps the match for every whole frame that has passed,
+// as long as the other player's inputs for it have arrived.
+// local holds the inputs on this side since the last update.
+// Returns an error once the match can't go on.
+func (s *Session) Update(local []engine.InputEvent, elapsed time.Duration) error {
+	if s.isClosed {
+		return net.ErrClosed
+	}
+
+	for _, e := range local {
+		e.Player = s.Local
+		s.pending = append(s.pending, e)
+	}
+	if err := s.receive(); err != nil {
+		return err
+	}
+
+	s.clock += elapsed
+	if s.MaxLag > 0 && s.clock-s.steppedTime > s.MaxLag {
+		s.steppedTime = s.clock - s.MaxLag
+	}
+
+	s.isWaiting = false
+	for s.clock-s.steppedTime >= engine.FrameDuration {
+		remote, ok := s.inputs[s.Remote()][s.frame]
+		if !ok {
+			s.isWaiting = true
+			break
+		}
+		// The other side checked frame - Delay before sending these inputs
+		if checked := s.frame - s.Delay; checked > 0 && checked%CheckInterval == 0 {
+			if _, ok := s.localChecks[checked]; ok {
+				return fmt.Errorf("the other side didn't check frame %d", checked)
+			}
+		}
+
+		// Local inputs are sent as they're scheduled, so the other side gets them Delay frames early
+		s.inputs[s.Local][s.frame+s.Delay] = s.pending
+		s.send(message_Inputs, encodeInputs(s.frame+s.Delay, s.pending))
+		s.pending = nil
+
+		// Both sides step with the inputs in player order
+		s.stepped = s.stepped[:0]
+		for i := range s.inputs {
+			if i == s.Remote() {
+				s.stepped = append(s.stepped, remote...)
+			} else {
+				s.stepped = append(s.stepped, s.inputs[i][s.frame]...)
+			}
+			delete(s.inputs[i], s.frame)
+		}
+		s.Match.Step(s.stepped)
+		s.frame++
+		s.steppedTime += engine.FrameDuration
+
+		if s.frame%CheckInterval == 0 {
+			hash := matchHash(s.Match)
+			s.send(message_Check, encodeCheck(s.frame, hash))
+			if err := s.check(s.frame, hash, s.localChecks, s.remoteChecks); err != nil {
+				return err
+			}
+		}
+	}
+
+	if s.err == nil {
+		if err := s.w.Flush(); err != nil {
+			s.err = err
+		}
+	}
+
+	if s.isWaiting {
+		// A player that left closes the connection, so that error isn't the reason
+		if s.hasLeft {
+			return ErrLeft
+		}
+		if s.err != nil {
+			return fmt.Errorf("connection lost: %w", s.err)
+		}
+	}
+	return nil
+}
+
+// receive handles the messages that have arrived
+func (s *Session) receive() error {
+	for {
+		var r received
+		select {
+		case r = <-s.received:
+		default:
+			return nil
+		}
+
+		if r.err != nil {
+			s.err = r.err
+			return nil
+		}
+
+		switch r.kind {
+		case message_Inputs:
+			frame, inputs, err := decodeInputs(r.payload, s.Remote())
+			if err != nil {
+				return fmt.Errorf("reading inputs: %w", err)
+			}
+			if frame < s.frame {
+				return fmt.Errorf("inputs for frame %d arrived after it was played", frame)
+			}
+			// The other side can be at most Delay frames ahead, sending inputs Delay frames ahead of that
+			if frame > s.frame+2*s.Delay {
+				return fmt.Errorf("inputs for frame %d arrived too early, at frame %d", frame, s.frame)
+			}
+			if _, ok := s.inputs[s.Remote()][frame]; ok {
+				return fmt.Errorf("inputs for frame %d arrived twice", frame)
+			}
+			s.inputs[s.Remote()][frame] = inputs
+		case message_Check:
+			frame, hash, err := decodeCheck(r.payload)
+			if err != nil {
+				return fmt.Errorf("reading check: %w", err)
+			}
+			// Checks are kept until this side gets to the frame, so only ones a few frames ahead are
+			if frame <= 0 || frame%CheckInterval != 0 || frame > s.frame+2*s.Delay {
+				return fmt.Errorf("check for frame %d arrived at frame %d", frame, s.frame)
+			}
+			_, isOurs := s.localChecks[frame]
+			if _, ok := s.remoteChecks[frame]; ok || (frame <= s.frame && !isOurs) {
+				return fmt.Errorf("check for frame %d arrived twice", frame)
+			}
+			if err := s.check(frame, hash, s.remoteChecks, s.localChecks); err != nil {
+				return err
+			}
+		case message_Bye:
+			s.hasLeft = true
+		default:
+			return fmt.Errorf("unexpected message %d", r.kind)
+		}
+	}
+}
+
+// check compares a hash with the other side's for the same frame, or keeps it until it arrives
+func (s *Session) check(frame int, hash uint64, ours, theirs map[int]uint64) error {
+	other, ok := theirs[frame]
+	if !ok {
+		ours[frame] = hash
+		return nil
+	}
+
+	delete(theirs, frame)
+	if hash != other {
+		return fmt.Errorf("%w at frame %d", ErrDesync, frame)
+	}
+	return nil
+}
+
+// send writes a message, it's sent on the next flush
+func (s *Session) send(kind messageType, payload []byte) {
+	if s.err != nil {
+		return
+	}
+
+	s.conn.SetWriteDeadline(time.Now().Add(Timeout))
+	if err := writeMessage(s.w, kind, payload); err != nil {
+		s.err = err
+	}
+}
+
+// Close says bye to the other player, and closes the connection
+func (s *Session) Close() error {
+	if s.isClosed {
+		return nil
+	}
+	s.isClosed = true
+
+	s.send(message_Bye, nil)
+	if s.err == nil {
+		s.w.Flush()
+	}
+	close(s.done)
+	return s.conn.Close()
+}
+
+// matchHash returns a hash of both players' games, which is the same on both sides while they're in sync
+func matchHash(v *engine.Versus) uint64 {
+	h := fnv.New64a()
+	buf := make([]byte, 8)
+	for _, gs := range v.Players {
+		for _, n := range []uint64{gs.Board.Hash(), uint64(gs.Score), uint64(gs.Frames()), uint64(gs.GarbageSent())} {
+			binary.LittleEndian.PutUint64(buf, n)
+			h.Write(buf)
+		}
+	}
+
+	return h.Sum64()
+}
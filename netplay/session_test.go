@@ -0,0 +1,319 @@
+package netplay
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"getris/engine"
+)
+
+func testOptions() Options {
+	return Options{Seed: 1, Rules: engine.DefaultRules(), Mode: engine.DefaultVersusMode(), Delay: DefaultDelay}
+}
+
+// pair hosts a match on loopback, and joins it
+func pair(t *testing.T, opts Options) (host, joiner *Session) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	type accepted struct {
+		s   *Session
+		err error
+	}
+	ch := make(chan accepted, 1)
+	go func() {
+		s, err := Accept(ln, opts, engine.DefaultHandling())
+		ch <- accepted{s, err}
+	}()
+
+	joiner, err = Join(ln.Addr().String(), engine.DefaultHandling(), Timeout)
+	if err != nil {
+		t.Fatalf("Join: %v", err)
+	}
+	a := <-ch
+	if a.err != nil {
+		joiner.Close()
+		t.Fatalf("Accept: %v", a.err)
+	}
+	host = a.s
+
+	t.Cleanup(func() {
+		host.Close()
+		joiner.Close()
+	})
+	return host, joiner
+}
+
+// play updates both sessions until they've stepped frames frames, or one fails.
+// The host hard drops every 20 frames, the joiner only moves left.
+func play(t *testing.T, host, joiner *Session, frames int) error {
+	sessions := []*Session{host, joiner}
+	// given is the time each session has been given, in frames, so neither steps past frames
+	given := make([]int, len(sessions))
+	deadline := time.Now().Add(10 * time.Second)
+
+	for host.frame < frames || joiner.frame < frames {
+		if time.Now().After(deadline) {
+			t.Fatalf("only got to frames %d and %d", host.frame, joiner.frame)
+		}
+
+		for i, s := range sessions {
+			var local []engine.InputEvent
+			elapsed := time.Duration(0)
+			if given[i] < frames {
+				press := engine.Input_HardDrop
+				if s == joiner {
+					press = engine.Input_MoveLeft
+				}
+				switch given[i] % 20 {
+				case 10:
+					local = []engine.InputEvent{{Input: press, Action: engine.Action_Down}}
+				case 11:
+					local = []engine.InputEvent{{Input: press, Action: engine.Action_Up}}
+				}
+				elapsed = engine.FrameDuration
+				given[i]++
+			}
+
+			if err := s.Update(local, elapsed); err != nil {
+				return err
+			}
+		}
+		if host.IsWaiting() || joiner.IsWaiting() {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	return nil
+}
+
+// updateUntilError updates s with nothing pressed until it fails
+func updateUntilError(t *testing.T, s *Session) error {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if err := s.Update(nil, engine.FrameDuration); err != nil {
+			return err
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	t.Fatalf("no error by frame %d", s.frame)
+	return nil
+}
+
+func TestHandshake(t *testing.T) {
+	opts := testOptions()
+	opts.Seed = 99
+	opts.Delay = 6
+	host, joiner := pair(t, opts)
+
+	if host.Local != 0 || joiner.Local != 1 {
+		t.Errorf("host is player %d and joiner player %d, want 0 and 1", host.Local, joiner.Local)
+	}
+	if joiner.Delay != opts.Delay {
+		t.Errorf("joiner has a delay of %d, want %d", joiner.Delay, opts.Delay)
+	}
+	if matchHash(host.Match) != matchHash(joiner.Match) {
+		t.Errorf("the matches start differently")
+	}
+}
+
+// fakePeer plays the other side of conn: it writes what's given, and reads everything that arrives
+func fakePeer(conn net.Conn, write ...[]byte) {
+	go io.Copy(io.Discard, conn)
+	go func() {
+		for _, b := range write {
+			if _, err := conn.Write(b); err != nil {
+				return
+			}
+		}
+	}()
+}
+
+func preamble(version uint64) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(buf, version)
+	return append(append([]byte{}, magic...), buf[:n]...)
+}
+
+func encodedMessage(t *testing.T, kind messageType, v interface{}) []byte {
+	var b strings.Builder
+	if err := writeJSON(&b, kind, v); err != nil {
+		t.Fatal(err)
+	}
+	return []byte(b.String())
+}
+
+func TestVersionMismatch(t *testing.T) {
+	t.Run("host", func(t *testing.T) {
+		conn, peer := net.Pipe()
+		defer conn.Close()
+		defer peer.Close()
+		fakePeer(peer, preamble(Version+1), encodedMessage(t, message_Hello, hello{}))
+
+		if _, err := acceptHandshake(conn, testOptions(), engine.DefaultHandling()); err == nil || !strings.Contains(err.Error(), "version") {
+			t.Errorf("got error %v, want one about the version", err)
+		}
+	})
+
+	t.Run("joiner", func(t *testing.T) {
+		conn, peer := net.Pipe()
+		defer conn.Close()
+		go io.Copy(io.Discard, peer)
+		go func() {
+			peer.Write(preamble(Version + 1))
+			peer.Close()
+		}()
+
+		if _, err := joinHandshake(conn, engine.DefaultHandling(), Timeout); err == nil || !strings.Contains(err.Error(), "version") {
+			t.Errorf("got error %v, want one about the version", err)
+		}
+	})
+}
+
+func TestBadWelcome(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(w *welcome)
+		want   string
+	}{
+		{"no delay", func(w *welcome) { w.Delay = 0 }, "delay"},
+		{"negative lock delay", func(w *welcome) { w.Rules.LockDelay = -1 }, "rules"},
+		{"too many previews", func(w *welcome) { w.Rules.Previews = engine.TetrominoQueueSize + 1 }, "rules"},
+		{"too messy", func(w *welcome) { w.Mode.Messiness = 2 }, "mode"},
+		{"negative garbage", func(w *welcome) { w.Mode.Attack.Tetris = -4 }, "mode"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := testOptions()
+			wel := welcome{Seed: opts.Seed, Rules: opts.Rules, Mode: opts.Mode, Handling: engine.DefaultHandling(), Delay: opts.Delay}
+			tt.change(&wel)
+
+			conn, peer := net.Pipe()
+			defer conn.Close()
+			defer peer.Close()
+			fakePeer(peer, preamble(Version), encodedMessage(t, message_Welcome, wel))
+
+			if _, err := joinHandshake(conn, engine.DefaultHandling(), Timeout); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want one about the %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAcceptBelowMinDelay(t *testing.T) {
+	opts := testOptions()
+	opts.Delay = MinDelay - 1
+	if _, err := Accept(nil, opts, engine.DefaultHandling()); err == nil {
+		t.Errorf("accepted with a delay of %d", opts.Delay)
+	}
+}
+
+func TestLockstep(t *testing.T) {
+	host, joiner := pair(t, testOptions())
+	if err := play(t, host, joiner, 5*CheckInterval); err != nil {
+		t.Fatal(err)
+	}
+
+	if matchHash(host.Match) != matchHash(joiner.Match) {
+		t.Errorf("the matches played out differently")
+	}
+	// The host's drops and the joiner's moves have to have reached the other side for the hashes to match,
+	// but check the inputs did something
+	for _, s := range []*Session{host, joiner} {
+		if s.Match.Players[0].Board.Hash() == s.Match.Players[1].Board.Hash() {
+			t.Errorf("player %d: both players' boards are the same", s.Local)
+		}
+	}
+	if len(host.localChecks)+len(host.remoteChecks)+len(joiner.localChecks)+len(joiner.remoteChecks) > 2 {
+		t.Errorf("checks are piling up: %v %v %v %v", host.localChecks, host.remoteChecks, joiner.localChecks, joiner.remoteChecks)
+	}
+}
+
+func TestBye(t *testing.T) {
+	host, joiner := pair(t, testOptions())
+	if err := play(t, host, joiner, 30); err != nil {
+		t.Fatal(err)
+	}
+
+	host.Close()
+	if err := updateUntilError(t, joiner); err != ErrLeft {
+		t.Errorf("got error %v, want %v", err, ErrLeft)
+	}
+}
+
+func TestDisconnect(t *testing.T) {
+	host, joiner := pair(t, testOptions())
+	if err := play(t, host, joiner, 30); err != nil {
+		t.Fatal(err)
+	}
+
+	// Gone without a bye
+	host.conn.Close()
+	if err := updateUntilError(t, joiner); err == ErrLeft || !strings.Contains(err.Error(), "connection lost") {
+		t.Errorf("got error %v, want the connection to be lost", err)
+	}
+}
+
+func TestDesync(t *testing.T) {
+	host, joiner := pair(t, testOptions())
+	if err := play(t, host, joiner, CheckInterval/2); err != nil {
+		t.Fatal(err)
+	}
+
+	host.Match.Players[1].Score++
+	if err := play(t, host, joiner, 2*CheckInterval); !errors.Is(err, ErrDesync) {
+		t.Errorf("got error %v, want %v", err, ErrDesync)
+	}
+}
+
+func TestBadMessages(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages []message
+		want     string
+	}{
+		{"inputs too early", []message{{message_Inputs, encodeInputs(3*DefaultDelay, nil)}}, "too early"},
+		{"inputs twice", []message{
+			{message_Inputs, encodeInputs(DefaultDelay, nil)},
+			{message_Inputs, encodeInputs(DefaultDelay, nil)},
+		}, "twice"},
+		{"unknown input", []message{{message_Inputs, encodeInputs(DefaultDelay, []engine.InputEvent{{Input: engine.Input(len(engine.Inputs))}})}}, "unknown input"},
+		{"check too early", []message{{message_Check, encodeCheck(1000*CheckInterval, 0)}}, "check for frame"},
+		{"check off the interval", []message{{message_Check, encodeCheck(1, 0)}}, "check for frame"},
+		{"check for frame 0", []message{{message_Check, encodeCheck(0, 0)}}, "check for frame"},
+		{"unknown message", []message{{message_Bye + 1, nil}}, "unexpected message"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, peer := net.Pipe()
+			defer peer.Close()
+			var writes [][]byte
+			for _, m := range tt.messages {
+				var b strings.Builder
+				writeMessage(&b, m.kind, m.payload)
+				writes = append(writes, []byte(b.String()))
+			}
+			fakePeer(peer, writes...)
+
+			handling := [engine.VersusPlayers]engine.Handling{engine.DefaultHandling(), engine.DefaultHandling()}
+			s := newSession(conn, bufio.NewReader(conn), bufio.NewWriter(conn), 0, testOptions(), handling)
+			defer s.Close()
+
+			if err := updateUntilError(t, s); !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want one about %q", err, tt.want)
+			}
+		})
+	}
+}
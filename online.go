@@ -0,0 +1,341 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+
+	"getris/engine"
+	"getris/netplay"
+	"getris/spectate"
+)
+
+// joinTimeout is how long joining waits for the host to answer
+const joinTimeout = 5 * time.Second
+
+// netWaitingShown is how long the match waits for the other player before it says so
+const netWaitingShown = 500 * time.Millisecond
+
+//// Host
+
+// hostScreen waits for a player to join a match, on s.hostAddr
+type hostScreen struct {
+	settings *settings
+	back     screen
+
+	listener net.Listener
+	// accepted gets the session once a player has joined, or nil once the listener is closed
+	accepted chan *netplay.Session
+	err      error
+}
+
+func newHostScreen(s *settings, back screen) *hostScreen {
+	hs := &hostScreen{settings: s, back: back, accepted: make(chan *netplay.Session, 1)}
+
+	hs.listener, hs.err = net.Listen("tcp", s.hostAddr)
+	if hs.err != nil {
+		return hs
+	}
+
+	opts := netplay.Options{
+		Seed:  s.nextSeed(),
+		Rules: s.rules,
+		Mode:  s.versus,
+		Delay: s.netDelay,
+	}
+	go func() {
+		for {
+			session, err := netplay.Accept(hs.listener, opts, s.handling)
+			if errors.Is(err, net.ErrClosed) {
+				hs.accepted <- nil
+				return
+			}
+			if err != nil {
+				// Someone failed to join, keep waiting for someone else
+				fmt.Fprintln(os.Stderr, "couldn't start a match with", err)
+				continue
+			}
+
+			hs.accepted <- session
+			return
+		}
+	}()
+
+	return hs
+}
+
+func (hs *hostScreen) Update() screen {
+	if rl.IsKeyPressed(rl.KeyEscape) {
+		if hs.listener != nil {
+			hs.listener.Close()
+			// Someone may have joined just now, let them go
+			go func() {
+				if session := <-hs.accepted; session != nil {
+					session.Close()
+				}
+			}()
+		}
+		return hs.back
+	}
+
+	select {
+	case session := <-hs.accepted:
+		hs.listener.Close()
+		if session != nil {
+			return newNetVersusScreen(hs.settings, session)
+		}
+	default:
+	}
+
+	return hs
+}
+
+func (hs *hostScreen) Draw() {
+	drawCenteredTextLine(hostText, menuTopY, menuTitleTextSize, textColor)
+
+	y := menuTopY + menuTitleTextSize + menuLineSpacing
+	if hs.err != nil {
+		drawCenteredTextLine(hs.err.Error(), y, menuTextSize, textColor)
+	} else {
+		drawCenteredTextLine(fmt.Sprintf("Waiting for a player on %s", hs.listener.Addr()), y, menuTextSize, textColor)
+	}
+	drawCenteredTextLine(cancelText, y+2*(menuTextSize+menuLineSpacing), menuTextSize, menuTextColor)
+}
+
+//// Join
+
+// joinScreen asks for the address of the host, then joins the match there
+type joinScreen struct {
+	settings *settings
+	back     screen
+
+	// joined gets the result of joining, while isJoining
+	joined    chan joinResult
+	isJoining bool
+	err       error
+}
+
+type joinResult struct {
+	session *netplay.Session
+	err     error
+}
+
+func newJoinScreen(s *settings, back screen) *joinScreen {
+	js := &joinScreen{settings: s, back: back}
+
+	// Letters typed before this screen aren't part of the address
+	for rl.GetCharPressed() != 0 {
+	}
+
+	return js
+}
+
+// startJoining joins the host at s.joinAddr, the result comes on js.joined
+func (js *joinScreen) startJoining() {
+	addr := js.settings.joinAddr
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, netplay.DefaultPort)
+	}
+
+	joined := make(chan joinResult, 1)
+	handling := js.settings.handling
+	go func() {
+		session, err := netplay.Join(addr, handling, joinTimeout)
+		joined <- joinResult{session: session, err: err}
+	}()
+
+	js.joined = joined
+	js.isJoining = true
+	js.err = nil
+}
+
+func (js *joinScreen) Update() screen {
+	if rl.IsKeyPressed(rl.KeyEscape) {
+		if js.isJoining {
+			// Joining may still work out, leave straight away if it does
+			joined := js.joined
+			go func() {
+				if r := <-joined; r.session != nil {
+					r.session.Close()
+				}
+			}()
+		}
+		return js.back
+	}
+
+	if js.isJoining {
+		select {
+		case r := <-js.joined:
+			js.isJoining = false
+			if r.err != nil {
+				js.err = r.err
+				return js
+			}
+			return newNetVersusScreen(js.settings, r.session)
+		default:
+		}
+		return js
+	}
+
+	// Type the address
+	for c := rl.GetCharPressed(); c != 0; c = rl.GetCharPressed() {
+		if c > ' ' && c < 0x7f {
+			js.settings.joinAddr += string(c)
+		}
+	}
+	if rl.IsKeyPressed(rl.KeyBackspace) && len(js.settings.joinAddr) > 0 {
+		js.settings.joinAddr = js.settings.joinAddr[:len(js.settings.joinAddr)-1]
+	}
+	if rl.IsKeyPressed(rl.KeyEnter) && js.settings.joinAddr != "" {
+		js.startJoining()
+	}
+
+	return js
+}
+
+func (js *joinScreen) Draw() {
+	drawCenteredTextLine(joinText, menuTopY, menuTitleTextSize, textColor)
+
+	y := menuTopY + menuTitleTextSize + menuLineSpacing
+	drawCenteredTextLine(js.settings.joinAddr+"_", y, menuTextSize, menuSelectedTextColor)
+	y += menuTextSize + menuLineSpacing
+
+	switch {
+	case js.isJoining:
+		drawCenteredTextLine("Joining...", y, menuTextSize, textColor)
+	case js.err != nil:
+		drawCenteredTextLine(js.err.Error(), y, menuTextSize, textColor)
+	}
+	drawCenteredTextLine(joinHintText, y+menuTextSize+menuLineSpacing, menuTextSize, menuTextColor)
+}
+
+//// Online versus
+
+// netVersusScreen plays a match with someone over the network.
+// The local player uses the usual keys and every gamepad.
+type netVersusScreen struct {
+	settings *settings
+	session  *netplay.Session
+	polled   []engine.InputEvent
+	// waiting is how long the match has been waiting for the other player
+	waiting time.Duration
+
+	layouts          [engine.VersusPlayers]layout
+	screenX, screenY int32
+}
+
+func newNetVersusScreen(s *settings, session *netplay.Session) *netVersusScreen {
+	session.MaxLag = maxUnsimulatedTime
+
+	ns := &netVersusScreen{settings: s, session: session}
+	ns.layouts, ns.screenX, ns.screenY = versusLayouts(gameLayout.cellSize)
+	return ns
+}
+
+func (ns *netVersusScreen) Update() screen {
+	match := ns.session.Match
+
+	// Leaving the match ends it for the other player too
+	if match.IsPaused && rl.IsKeyPressed(quitGameKey) {
+		ns.session.Close()
+		return newModeSelectScreen(ns.settings)
+	}
+
+	ns.polled = ns.settings.input.Poll(ns.polled[:0])
+	elapsed := time.Duration(float64(rl.GetFrameTime()) * float64(time.Second))
+	if err := ns.session.Update(ns.polled, elapsed); err != nil {
+		ns.session.Close()
+		return newNetErrorScreen(ns.settings, err)
+	}
+
+	if ns.session.IsWaiting() {
+		ns.waiting += elapsed
+	} else {
+		ns.waiting = 0
+	}
+
+	if match.IsDone() {
+		ns.session.Close()
+
+		var wins [engine.VersusPlayers]int
+		if match.Winner != engine.NoWinner {
+			wins[match.Winner]++
+		}
+		over := newVersusOverScreen(ns.settings, match, wins, nil)
+		switch match.Winner {
+		case ns.session.Local:
+			over.menu.title = youText + " " + winText
+		case ns.session.Remote():
+			over.menu.title = youText + " " + loseText
+		}
+		return over
+	}
+
+	return ns
+}
+
+func (ns *netVersusScreen) Draw() {
+	drawVersus(ns.session.Match, &ns.layouts, func(i int, gs *engine.GameState) []scoreLine {
+		player := fmt.Sprint(i + 1)
+		if i == ns.session.Local {
+			player += " " + youText
+		}
+
+		return []scoreLine{
+			{label: playerText, value: player},
+			{label: linesText, value: fmt.Sprint(gs.LinesCleared())},
+			{label: sentText, value: fmt.Sprint(gs.GarbageSent())},
+		}
+	})
+
+	if ns.waiting >= netWaitingShown {
+		drawCenteredTextLine(netWaitingText, -int32(titleTextSize), menuTextSize, textColor)
+	}
+}
+
+func (ns *netVersusScreen) screenSize() (x, y int32) {
+	return ns.screenX, ns.screenY
+}
+
+func (ns *netVersusScreen) spectated() spectate.State {
+	return spectate.Capture(ns.session.Match.Players[:]...)
+}
+
+//// Errors
+
+// netErrorScreen shows why an online match ended early
+type netErrorScreen struct {
+	menu    menu
+	message string
+}
+
+func newNetErrorScreen(s *settings, err error) *netErrorScreen {
+	return &netErrorScreen{
+		message: err.Error(),
+		menu: menu{
+			title: disconnectedText,
+			items: []menuItem{
+				{label: "Menu", selected: func() screen { return newTitleScreen(s) }},
+			},
+		},
+	}
+}
+
+func (es *netErrorScreen) Update() screen {
+	if next, ok := es.menu.update(); ok {
+		return next
+	}
+
+	return es
+}
+
+func (es *netErrorScreen) Draw() {
+	es.menu.draw(menuTopY)
+
+	y := menuTopY + menuTitleTextSize + menuLineSpacing + int32(len(es.menu.items))*(menuTextSize+menuLineSpacing) + menuLineSpacing
+	drawCenteredTextLine(es.message, y, menuTextSize, textColor)
+}
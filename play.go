@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+
+	"getris/engine"
+	"getris/records"
+	"getris/replay"
+	"getris/spectate"
+)
+
+// playScreen runs a game until it ends
+type playScreen struct {
+	settings *settings
+	mode     engine.Mode
+	game     *engine.GameState
+	replay   *replay.Replay
+
+	// runner steps the game, with the inputs polled every rendered frame
+	runner *engine.Runner
+	polled []engine.InputEvent
+}
+
+func newPlayScreen(s *settings, mode engine.Mode) *playScreen {
+	r := replay.New(s.rules, s.handling, mode, s.nextSeed())
+	game := r.NewGame()
+	runner := engine.NewRunner(game)
+	runner.MaxLag = maxUnsimulatedTime
+	runner.OnStep = r.Record
+
+	return &playScreen{
+		settings: s,
+		mode:     mode,
+		game:     game,
+		replay:   r,
+		runner:   runner,
+	}
+}
+
+func (ps *playScreen) Update() screen {
+	// Leave the game from the pause screen
+	if ps.game.Phase == engine.Phase_Paused && rl.IsKeyPressed(quitGameKey) {
+		ps.replay.Finish(ps.game)
+		return newGameOverScreen(ps.settings, ps.replay, ps.game, -1)
+	}
+
+	ps.polled = ps.settings.input.Poll(ps.polled[:0])
+	ps.runner.Push(ps.polled...)
+
+	// Step the game once for every frame that has passed,
+	// so it runs at the same speed no matter the render rate
+	ps.runner.Advance(time.Duration(float64(rl.GetFrameTime()) * float64(time.Second)))
+
+	if ps.game.IsDone {
+		ps.replay.Finish(ps.game)
+		return newGameOverScreen(ps.settings, ps.replay, ps.game, ps.submitRecord())
+	}
+
+	return ps
+}
+
+func (ps *playScreen) Draw() {
+	gameLayout.drawGame(ps.game, scoreLines(ps.settings, ps.mode, ps.game))
+}
+
+func (ps *playScreen) spectated() spectate.State {
+	return spectate.Capture(ps.game)
+}
+
+// scoreLines returns what's shown beside the board, for the mode being played
+func scoreLines(s *settings, mode engine.Mode, game *engine.GameState) []scoreLine {
+	switch mode := mode.(type) {
+	case engine.MarathonMode:
+		goal := fmt.Sprint(mode.LinesLeft(game))
+		if mode.IsFinished(game) {
+			goal = "0"
+		}
+
+		return []scoreLine{
+			{label: levelText, value: fmt.Sprint(game.Level())},
+			{label: scoreText, value: fmt.Sprint(game.Score)},
+			{label: goalText, value: goal},
+		}
+	case engine.SprintMode:
+		linesLeft := mode.Lines - game.LinesCleared()
+		if linesLeft < 0 {
+			linesLeft = 0
+		}
+
+		lines := []scoreLine{
+			{label: timeText, value: formatTime(game.Time())},
+			{label: linesText, value: fmt.Sprint(linesLeft)},
+		}
+		if pace, ok := sprintPace(s, mode, game); ok {
+			color := aheadTextColor
+			if pace > 0 {
+				color = behindTextColor
+			}
+			lines = append(lines, scoreLine{label: paceText, value: formatTimeDifference(pace), valueColor: color})
+		}
+
+		return lines
+	case engine.DigMode:
+		return []scoreLine{
+			{label: timeText, value: formatTime(game.Time())},
+			{label: garbageText, value: fmt.Sprint(mode.GarbageLeft(game))},
+		}
+	case engine.MasterMode:
+		return []scoreLine{
+			{label: levelText, value: fmt.Sprintf("%d/%d", game.Level(), mode.SectionEnd(game))},
+			{label: gradeText, value: mode.Grade(game)},
+			{label: timeText, value: formatTime(game.Time())},
+		}
+	case engine.ZenMode:
+		return []scoreLine{
+			{label: scoreText, value: fmt.Sprint(game.Score)},
+			{label: linesText, value: fmt.Sprint(game.LinesCleared())},
+		}
+	case engine.UltraMode:
+		return []scoreLine{
+			{label: timeText, value: formatTime(mode.TimeLeft(game))},
+			{label: scoreText, value: fmt.Sprint(game.Score)},
+			{label: linesText, value: fmt.Sprint(game.LinesCleared())},
+		}
+	}
+
+	return []scoreLine{
+		{label: levelText, value: fmt.Sprint(game.Level())},
+		{label: scoreText, value: fmt.Sprint(game.Score)},
+	}
+}
+
+// sprintPace compares the time the last line was cleared at, to the same line in the personal best.
+// Negative if ahead of it.
+func sprintPace(s *settings, mode engine.SprintMode, game *engine.GameState) (time.Duration, bool) {
+	key, _, _, _ := recordTable(mode)
+	best, ok := s.records.Best(key)
+	if !ok {
+		return 0, false
+	}
+
+	lines := game.LinesCleared()
+	if lines > len(best.Splits) {
+		lines = len(best.Splits)
+	}
+	if lines == 0 {
+		return 0, false
+	}
+
+	split := engine.Duration(game.LineFrames()[lines-1])
+	return split - best.Splits[lines-1], true
+}
+
+// recordTable returns the key, title and ranking of the mode's table in the records.
+// Returns false for modes that aren't kept.
+func recordTable(mode engine.Mode) (key, title string, ranking records.Ranking, ok bool) {
+	switch mode := mode.(type) {
+	case engine.MarathonMode:
+		if mode.EndLevel == 0 {
+			return fmt.Sprintf("marathon-%d-endless", mode.StartLevel),
+				fmt.Sprintf("Marathon %d endless", mode.StartLevel), records.Ranking_Score, true
+		}
+		return fmt.Sprintf("marathon-%d-%d-%s", mode.StartLevel, mode.EndLevel, mode.Goal),
+			fmt.Sprintf("Marathon %d-%d %s", mode.StartLevel, mode.EndLevel, mode.Goal), records.Ranking_Score, true
+	case engine.SprintMode:
+		// Keys and titles for sprints and ultras match the ones upgraded from the first records file
+		return fmt.Sprintf("sprint-%d", mode.Lines), fmt.Sprintf("Sprint %d lines", mode.Lines), records.Ranking_Time, true
+	case engine.UltraMode:
+		seconds := int(mode.TimeLimit / time.Second)
+		title := fmt.Sprintf("Ultra %d min", seconds/60)
+		if seconds%60 != 0 {
+			// Limits from replays don't have to be whole minutes
+			title = fmt.Sprintf("Ultra %v", time.Duration(seconds)*time.Second)
+		}
+		return fmt.Sprintf("ultra-%d", seconds), title, records.Ranking_Score, true
+	case engine.DigMode:
+		return fmt.Sprintf("dig-%d-%d-%d", mode.Rows, mode.Height, percent(mode.Messiness)),
+			fmt.Sprintf("Dig %d rows %d high %d%%", mode.Rows, mode.Height, percent(mode.Messiness)), records.Ranking_Time, true
+	case engine.MasterMode:
+		return "master", "Master", records.Ranking_Score, true
+	}
+
+	return "", "", 0, false
+}
+
+// submitRecord keeps the game if it's one of the best, and links its replay.
+// Games ranked by time only count if they were finished.
+// Returns its place in the records starting from 0, or -1 if it wasn't kept.
+func (ps *playScreen) submitRecord() int {
+	key, title, ranking, ok := recordTable(ps.mode)
+	if !ok || (ranking == records.Ranking_Time && !ps.mode.IsFinished(ps.game)) {
+		return -1
+	}
+
+	entry := records.Entry{
+		Name:   ps.settings.name,
+		Date:   time.Now(),
+		Score:  ps.game.Score,
+		Lines:  ps.game.LinesCleared(),
+		Level:  ps.game.Level(),
+		Time:   ps.game.Time(),
+		Replay: ps.replay.FileName(),
+	}
+	if mode, ok := ps.mode.(engine.SprintMode); ok {
+		for _, frame := range ps.game.LineFrames()[:mode.Lines] {
+			entry.Splits = append(entry.Splits, engine.Duration(frame))
+		}
+	}
+
+	place := ps.settings.records.Submit(key, title, ranking, entry)
+	if place < 0 {
+		return place
+	}
+
+	// Games in the records keep their replay
+	if err := saveReplay(ps.replay); err != nil {
+		ps.settings.records.Table(key).Entries[place].Replay = ""
+	}
+	if err := ps.settings.records.Save(); err != nil {
+		fmt.Fprintln(os.Stderr, "couldn't save records:", err)
+	}
+
+	return place
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+
+	"getris/engine"
+	"getris/replay"
+	"getris/spectate"
+)
+
+//// Playback
+
+// playbackScreen plays a replay, instead of a game driven by the keyboard
+type playbackScreen struct {
+	settings *settings
+	player   *replay.Player
+	back     screen
+
+	paused bool
+	// speed is how many times faster than real time the replay plays
+	speed       float64
+	unsimulated time.Duration
+}
+
+func newPlaybackScreen(s *settings, r *replay.Replay, back screen) *playbackScreen {
+	return &playbackScreen{
+		settings: s,
+		player:   replay.NewPlayer(r),
+		back:     back,
+		speed:    1,
+	}
+}
+
+func (ps *playbackScreen) Update() screen {
+	// Playback doesn't use the key queue, empty it so keys aren't sent to the next game
+	for rl.GetKeyPressed() != 0 {
+	}
+
+	switch {
+	case rl.IsKeyPressed(rl.KeyEscape), rl.IsKeyPressed(quitGameKey):
+		return ps.back
+	case rl.IsKeyPressed(rl.KeySpace):
+		ps.paused = !ps.paused
+	case rl.IsKeyPressed(rl.KeyLeft):
+		ps.player.Seek(ps.player.Position() - playbackSeekFrames)
+	case rl.IsKeyPressed(rl.KeyRight):
+		ps.player.Seek(ps.player.Position() + playbackSeekFrames)
+	case rl.IsKeyPressed(rl.KeyUp):
+		ps.speed = math.Min(ps.speed*2, playbackMaxSpeed)
+	case rl.IsKeyPressed(rl.KeyDown):
+		ps.speed = math.Max(ps.speed/2, playbackMinSpeed)
+	case rl.IsKeyPressed(rl.KeyPeriod):
+		if ps.paused {
+			ps.player.Step()
+		}
+	}
+
+	if ps.paused {
+		ps.unsimulated = 0
+		return ps
+	}
+
+	// Like playing, but time passes faster or slower
+	ps.unsimulated += time.Duration(float64(rl.GetFrameTime()) * ps.speed * float64(time.Second))
+	if limit := time.Duration(float64(maxUnsimulatedTime) * ps.speed); ps.unsimulated > limit {
+		ps.unsimulated = limit
+	}
+	for ps.unsimulated >= engine.FrameDuration && ps.player.Step() {
+		ps.unsimulated -= engine.FrameDuration
+	}
+
+	return ps
+}
+
+func (ps *playbackScreen) Draw() {
+	r := ps.player.Replay
+	gameLayout.drawGame(ps.player.Game, scoreLines(ps.settings, r.Mode, ps.player.Game))
+
+	status := fmt.Sprintf("%s / %s  x%g", formatTime(engine.Duration(ps.player.Position())), formatTime(r.Duration()), ps.speed)
+	if ps.paused {
+		status += "  " + pausedText
+	}
+	_, screenY := gameLayout.screenSize()
+	drawCenteredTextLine(status, screenY/2-2*menuTextSize, menuTextSize, textColor)
+}
+
+func (ps *playbackScreen) spectated() spectate.State {
+	return spectate.Capture(ps.player.Game)
+}
+
+//// Replays
+
+// replaysScreen lists the saved replays, newest first
+type replaysScreen struct {
+	menu     menu
+	settings *settings
+}
+
+func newReplaysScreen(s *settings) *replaysScreen {
+	rs := &replaysScreen{settings: s}
+
+	var items []menuItem
+	for _, path := range savedReplays() {
+		path := path
+		items = append(items, menuItem{
+			label: strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+			selected: func() screen {
+				r, err := replay.Load(path)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, "couldn't load replay:", err)
+					return rs
+				}
+				return newPlaybackScreen(s, r, rs)
+			},
+		})
+	}
+	items = append(items, menuItem{label: "Back", selected: func() screen { return newTitleScreen(s) }})
+
+	rs.menu = menu{title: replaysText, items: items}
+	return rs
+}
+
+// savedReplays returns the paths of the newest replays in the replays directory
+func savedReplays() []string {
+	dir, err := replay.DefaultDir()
+	if err != nil {
+		return nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	type saved struct {
+		path    string
+		modTime time.Time
+	}
+	var replays []saved
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != replay.Extension && ext != replay.JSONExtension) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		replays = append(replays, saved{filepath.Join(dir, entry.Name()), info.ModTime()})
+	}
+
+	sort.Slice(replays, func(i, j int) bool {
+		return replays[i].modTime.After(replays[j].modTime)
+	})
+	if len(replays) > replaysShown {
+		replays = replays[:replaysShown]
+	}
+
+	paths := make([]string, len(replays))
+	for i, r := range replays {
+		paths[i] = r.path
+	}
+	return paths
+}
+
+func (rs *replaysScreen) Update() screen {
+	if rl.IsKeyPressed(rl.KeyEscape) {
+		return newTitleScreen(rs.settings)
+	}
+	if next, ok := rs.menu.update(); ok {
+		return next
+	}
+
+	return rs
+}
+
+func (rs *replaysScreen) Draw() {
+	rs.menu.draw(menuTopY)
+}
@@ -0,0 +1,57 @@
+package records
+
+import (
+	"fmt"
+)
+
+// Ranking decides which entries in a table are better
+type Ranking int
+
+const (
+	// Score: Higher scores are better.
+	Ranking_Score Ranking = iota
+	// Time: Faster times are better.
+	Ranking_Time
+)
+
+var rankingNames = [...]string{
+	Ranking_Score: "score",
+	Ranking_Time:  "time",
+}
+
+func (k Ranking) String() string {
+	if k < 0 || int(k) >= len(rankingNames) {
+		return fmt.Sprintf("Ranking(%d)", int(k))
+	}
+
+	return rankingNames[k]
+}
+
+// ParseRanking is the inverse of Ranking.String
+func ParseRanking(name string) (Ranking, error) {
+	for k, n := range rankingNames {
+		if n == name {
+			return Ranking(k), nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown ranking %q", name)
+}
+
+func (k Ranking) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+func (k *Ranking) UnmarshalText(text []byte) (err error) {
+	*k, err = ParseRanking(string(text))
+	return err
+}
+
+// isBetter returns true if a is strictly better than b
+func (k Ranking) isBetter(a, b Entry) bool {
+	if k == Ranking_Time {
+		return a.Time < b.Time
+	}
+
+	return a.Score > b.Score
+}
@@ -0,0 +1,260 @@
+// Package records keeps the best games of each mode between games, in a JSON file
+package records
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// SchemaVersion is the version of the records file written by this version of getris.
+// Files from newer versions are refused, older ones are upgraded when they're loaded.
+//
+// Versions:
+//   - 1: Only sprint bests and ultra scores, without a version field
+//   - 2: A table of the best games for every mode
+const SchemaVersion int = 2
+
+// TableSize is how many entries each table keeps
+const TableSize int = 10
+
+// Entry is a game kept in a table
+type Entry struct {
+	Name  string        `json:"name"`
+	Date  time.Time     `json:"date"`
+	Score int           `json:"score"`
+	Lines int           `json:"lines"`
+	Level int           `json:"level"`
+	Time  time.Duration `json:"time"`
+	// Splits holds the time each line was cleared at, in order. Only kept for sprints.
+	Splits []time.Duration `json:"splits,omitempty"`
+	// Replay is the file name of the game's replay in the replays directory, empty if it wasn't saved
+	Replay string `json:"replay,omitempty"`
+}
+
+// Table holds the best games of a mode, with the same options
+type Table struct {
+	// Title describes the mode and its options
+	Title   string  `json:"title"`
+	Ranking Ranking `json:"ranking"`
+	// Entries are best first, and there are at most TableSize of them
+	Entries []Entry `json:"entries"`
+}
+
+// Records are the best games of every mode
+type Records struct {
+	Version int `json:"version"`
+	// Tables are keyed by the mode and its options, like "sprint-40"
+	Tables map[string]*Table `json:"tables"`
+
+	// path is where the records are saved, nothing is saved if it's empty
+	path string
+}
+
+// DefaultPath returns where records are kept, in the user's config directory
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "getris", "records.json"), nil
+}
+
+// New returns empty records, that will be saved to path
+func New(path string) *Records {
+	return &Records{
+		Version: SchemaVersion,
+		Tables:  map[string]*Table{},
+		path:    path,
+	}
+}
+
+// Load reads the records at path.
+// A missing file isn't an error, it's the same as having no records yet.
+// Always returns usable records, even with an error,
+// but they won't be saved so the file isn't overwritten.
+func Load(path string) (*Records, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return New(path), nil
+	}
+	if err != nil {
+		return New(""), err
+	}
+
+	var version struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &version); err != nil {
+		return New(""), err
+	}
+
+	r := New(path)
+	switch {
+	case version.Version > SchemaVersion:
+		return New(""), fmt.Errorf("records version %d is newer than %d, update getris to keep them", version.Version, SchemaVersion)
+	case version.Version <= 1:
+		err = r.upgradeFromV1(data)
+	default:
+		err = json.Unmarshal(data, r)
+	}
+	if err != nil {
+		return New(""), err
+	}
+
+	r.Version = SchemaVersion
+	if r.Tables == nil {
+		r.Tables = map[string]*Table{}
+	}
+
+	return r, nil
+}
+
+// upgradeFromV1 moves the sprint bests and ultra scores of a version 1 file into tables.
+// The keys and titles have to match the ones the game uses for the same modes.
+func (r *Records) upgradeFromV1(data []byte) error {
+	var v1 struct {
+		Sprint map[int]struct {
+			Time   time.Duration   `json:"time"`
+			Splits []time.Duration `json:"splits"`
+			Date   time.Time       `json:"date"`
+		} `json:"sprint"`
+		Ultra map[int][]struct {
+			Score int       `json:"score"`
+			Lines int       `json:"lines"`
+			Date  time.Time `json:"date"`
+		} `json:"ultra"`
+	}
+	if err := json.Unmarshal(data, &v1); err != nil {
+		return err
+	}
+
+	for lines, s := range v1.Sprint {
+		r.Submit(fmt.Sprintf("sprint-%d", lines), fmt.Sprintf("Sprint %d lines", lines), Ranking_Time, Entry{
+			Date:   s.Date,
+			Lines:  lines,
+			Time:   s.Time,
+			Splits: s.Splits,
+		})
+	}
+	for seconds, table := range v1.Ultra {
+		for _, u := range table {
+			r.Submit(fmt.Sprintf("ultra-%d", seconds), fmt.Sprintf("Ultra %d min", seconds/60), Ranking_Score, Entry{
+				Date:  u.Date,
+				Score: u.Score,
+				Lines: u.Lines,
+				Time:  time.Duration(seconds) * time.Second,
+			})
+		}
+	}
+
+	return nil
+}
+
+// Save writes the records back to the file they were loaded from.
+// The file is replaced at once, so it's never left half written.
+func (r *Records) Save() error {
+	if r.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(r, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(r.path, data)
+}
+
+// writeFileAtomic writes data to a temporary file next to path, then renames it over path
+func writeFileAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+// Table returns the table with the given key, nil if no game has been kept in it
+func (r *Records) Table(key string) *Table {
+	return r.Tables[key]
+}
+
+// Keys returns the key of every table, ordered by title
+func (r *Records) Keys() []string {
+	keys := make([]string, 0, len(r.Tables))
+	for key := range r.Tables {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return r.Tables[keys[i]].Title < r.Tables[keys[j]].Title
+	})
+	return keys
+}
+
+// Best returns the best entry in the table with the given key
+func (r *Records) Best(key string) (Entry, bool) {
+	t := r.Tables[key]
+	if t == nil || len(t.Entries) == 0 {
+		return Entry{}, false
+	}
+
+	return t.Entries[0], true
+}
+
+// Submit adds the entry to the table with the given key, if it's good enough.
+// The table is created with title and ranking, if it doesn't exist yet.
+// Returns its place in the table starting from 0, or -1 if it didn't make it.
+func (r *Records) Submit(key, title string, ranking Ranking, e Entry) int {
+	t := r.Tables[key]
+	if t == nil {
+		t = &Table{Title: title, Ranking: ranking}
+		r.Tables[key] = t
+	}
+
+	// Later entries go below earlier ones that are as good
+	place := len(t.Entries)
+	for i, other := range t.Entries {
+		if t.Ranking.isBetter(e, other) {
+			place = i
+			break
+		}
+	}
+	if place >= TableSize {
+		return -1
+	}
+
+	t.Entries = append(t.Entries, Entry{})
+	copy(t.Entries[place+1:], t.Entries[place:])
+	t.Entries[place] = e
+	if len(t.Entries) > TableSize {
+		t.Entries = t.Entries[:TableSize]
+	}
+
+	return place
+}
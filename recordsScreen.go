@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+
+	"getris/records"
+	"getris/replay"
+)
+
+//// Records
+
+// recordsScreen shows the tables in the records, one at a time.
+// Entries with a replay can be watched.
+type recordsScreen struct {
+	settings *settings
+	keys     []string
+	// table is the index of the key of the table shown
+	table  int
+	cursor int
+}
+
+func newRecordsScreen(s *settings) *recordsScreen {
+	return &recordsScreen{
+		settings: s,
+		keys:     s.records.Keys(),
+	}
+}
+
+func (rs *recordsScreen) Update() screen {
+	// The key queue isn't used, empty it so keys aren't sent to the next game
+	for rl.GetKeyPressed() != 0 {
+	}
+
+	if rl.IsKeyPressed(rl.KeyEscape) || rl.IsKeyPressed(quitGameKey) {
+		return newTitleScreen(rs.settings)
+	}
+	if len(rs.keys) == 0 {
+		return rs
+	}
+
+	entries := rs.settings.records.Table(rs.keys[rs.table]).Entries
+	switch {
+	case rl.IsKeyPressed(rl.KeyLeft):
+		rs.table = cycle(rs.table, -1, len(rs.keys))
+		rs.cursor = 0
+	case rl.IsKeyPressed(rl.KeyRight):
+		rs.table = cycle(rs.table, 1, len(rs.keys))
+		rs.cursor = 0
+	case rl.IsKeyPressed(rl.KeyUp):
+		rs.cursor = cycle(rs.cursor, -1, len(entries))
+	case rl.IsKeyPressed(rl.KeyDown):
+		rs.cursor = cycle(rs.cursor, 1, len(entries))
+	case rl.IsKeyPressed(rl.KeyEnter), rl.IsKeyPressed(rl.KeySpace):
+		if rs.cursor < len(entries) && entries[rs.cursor].Replay != "" {
+			if r, err := loadLinkedReplay(entries[rs.cursor].Replay); err == nil {
+				return newPlaybackScreen(rs.settings, r, rs)
+			}
+		}
+	}
+
+	return rs
+}
+
+// loadLinkedReplay loads a replay that's linked from the records, by its file name
+func loadLinkedReplay(name string) (*replay.Replay, error) {
+	dir, err := replay.DefaultDir()
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := replay.Load(filepath.Join(dir, name))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "couldn't load replay:", err)
+	}
+	return r, err
+}
+
+func (rs *recordsScreen) Draw() {
+	y := menuTopY
+	drawCenteredTextLine(recordsText, y, menuTitleTextSize, textColor)
+	y += menuTitleTextSize + menuLineSpacing
+
+	if len(rs.keys) == 0 {
+		drawCenteredTextLine(noRecordsText, y, menuTextSize, menuTextColor)
+		return
+	}
+
+	table := rs.settings.records.Table(rs.keys[rs.table])
+	drawCenteredTextLine("< "+table.Title+" >", y, menuTextSize, menuSelectedTextColor)
+	y += menuTextSize + menuLineSpacing
+
+	drawRecordTable(table, 0, records.TableSize, rs.cursor, y)
+	y += int32(records.TableSize+1) * recordLineSizeY
+
+	if rs.cursor < len(table.Entries) && table.Entries[rs.cursor].Replay != "" {
+		drawCenteredTextLine(watchReplayText, y+menuLineSpacing, menuTextSize, menuTextColor)
+	}
+}
+
+// drawRecordTable draws a header, then up to rows entries of the table starting from first.
+// The entry at highlight is drawn in the selected color, -1 to highlight none.
+func drawRecordTable(t *records.Table, first, rows, highlight int, y int32) {
+	type column struct {
+		header string
+		width  int32
+		value  func(place int, e records.Entry) string
+	}
+	columns := []column{
+		{"", 30, func(place int, e records.Entry) string { return fmt.Sprintf("%d.", place+1) }},
+		{nameText, 150, func(place int, e records.Entry) string { return e.Name }},
+		{scoreText, 100, func(place int, e records.Entry) string { return fmt.Sprint(e.Score) }},
+		{timeText, 100, func(place int, e records.Entry) string { return formatTime(e.Time) }},
+		{linesText, 60, func(place int, e records.Entry) string { return fmt.Sprint(e.Lines) }},
+		{levelText, 60, func(place int, e records.Entry) string { return fmt.Sprint(e.Level) }},
+		{dateText, 110, func(place int, e records.Entry) string { return e.Date.Format("2006-01-02") }},
+	}
+
+	width := int32(0)
+	for _, c := range columns {
+		width += c.width
+	}
+	drawRow := func(y int32, color rl.Color, text func(c column) string) {
+		x := -(width / 2)
+		for _, c := range columns {
+			rl.DrawText(text(c), x, y, recordTextSize, color)
+			x += c.width
+		}
+	}
+
+	drawRow(y, textColor, func(c column) string { return c.header })
+	for place := first; place < first+rows && place < len(t.Entries); place++ {
+		y += recordLineSizeY
+
+		color := menuTextColor
+		if place == highlight {
+			color = menuSelectedTextColor
+		}
+		e := t.Entries[place]
+		drawRow(y, color, func(c column) string { return c.value(place, e) })
+	}
+}
@@ -0,0 +1,368 @@
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"getris/engine"
+)
+
+// Replays are saved in a compact binary format by default, or as JSON if the file ends in .json.
+//
+// The binary format is:
+//   - magic, the 4 bytes "GTRP"
+//   - the version, as a uvarint
+//   - the length of the header, as a uvarint, then the header as JSON.
+//     It holds everything but the events, which are small next to them.
+//   - the number of events, as a uvarint
+//   - every event, as the steps since the last event, the input and the action, each as a uvarint
+
+const (
+	// Extension is used for replays saved in the binary format, JSONExtension for the JSON format
+	Extension     string = ".gtr"
+	JSONExtension string = ".json"
+)
+
+var magic = []byte("GTRP")
+
+const (
+	// maxHeader is the longest header read, so a bad file can't make us allocate anything bigger
+	maxHeader = 1 << 16
+	// maxEvents is the most events read, hours of play
+	maxEvents = 1 << 24
+	// maxSteps is the most steps a replay can have, a day of play, so verifying one always ends
+	maxSteps = 24 * 60 * 60 * engine.FramesPerSecond
+)
+
+// header is everything in a replay, except the events
+type header struct {
+	Version  int             `json:"version"`
+	Date     time.Time       `json:"date"`
+	Seed     int64           `json:"seed"`
+	Rules    engine.Rules    `json:"rules"`
+	Handling engine.Handling `json:"handling"`
+	Mode     modeJSON        `json:"mode"`
+	Steps    int             `json:"steps"`
+	Result   Result          `json:"result"`
+}
+
+// replayJSON is a replay in the JSON format
+type replayJSON struct {
+	header
+	Events []Event `json:"events"`
+}
+
+// modeJSON holds a mode's name, and its options
+type modeJSON struct {
+	Name    string          `json:"name"`
+	Options json.RawMessage `json:"options"`
+}
+
+func encodeMode(mode engine.Mode) (modeJSON, error) {
+	options, err := json.Marshal(mode)
+	return modeJSON{Name: mode.Name(), Options: options}, err
+}
+
+func decodeMode(m modeJSON) (engine.Mode, error) {
+	switch m.Name {
+	case engine.MarathonMode{}.Name():
+		var mode engine.MarathonMode
+		if err := json.Unmarshal(m.Options, &mode); err != nil {
+			return nil, err
+		}
+		return mode, mode.Check()
+	case engine.SprintMode{}.Name():
+		var mode engine.SprintMode
+		if err := json.Unmarshal(m.Options, &mode); err != nil {
+			return nil, err
+		}
+		return mode, mode.Check()
+	case engine.UltraMode{}.Name():
+		var mode engine.UltraMode
+		if err := json.Unmarshal(m.Options, &mode); err != nil {
+			return nil, err
+		}
+		return mode, mode.Check()
+	case engine.DigMode{}.Name():
+		var mode engine.DigMode
+		if err := json.Unmarshal(m.Options, &mode); err != nil {
+			return nil, err
+		}
+		return mode, mode.Check()
+	case engine.MasterMode{}.Name():
+		var mode engine.MasterMode
+		err := json.Unmarshal(m.Options, &mode)
+		return mode, err
+	case engine.ZenMode{}.Name():
+		var mode engine.ZenMode
+		err := json.Unmarshal(m.Options, &mode)
+		return mode, err
+	}
+
+	return nil, fmt.Errorf("unknown mode %q", m.Name)
+}
+
+// newHeader returns a header to decode into.
+// Rules that older replays don't have keep their defaults, which is how those games were played.
+func newHeader() header {
+	return header{Rules: engine.DefaultRules()}
+}
+
+func (r *Replay) header() (header, error) {
+	mode, err := encodeMode(r.Mode)
+	return header{
+		Version:  r.Version,
+		Date:     r.Date,
+		Seed:     r.Seed,
+		Rules:    r.Rules,
+		Handling: r.Handling,
+		Mode:     mode,
+		Steps:    r.Steps,
+		Result:   r.Result,
+	}, err
+}
+
+func fromHeader(h header) (*Replay, error) {
+	if h.Version > Version {
+		return nil, fmt.Errorf("replay version %d is newer than %d, update getris to play it", h.Version, Version)
+	}
+
+	mode, err := decodeMode(h.Mode)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.Rules.Check(); err != nil {
+		return nil, fmt.Errorf("rules: %w", err)
+	}
+	if h.Steps < 0 || h.Steps > maxSteps {
+		return nil, fmt.Errorf("replay has %d steps, it must have from 0 to %d", h.Steps, maxSteps)
+	}
+
+	return &Replay{
+		Version:  h.Version,
+		Date:     h.Date,
+		Seed:     h.Seed,
+		Rules:    h.Rules,
+		Handling: h.Handling,
+		Mode:     mode,
+		Steps:    h.Steps,
+		Result:   h.Result,
+	}, nil
+}
+
+//// JSON
+
+func (r *Replay) MarshalJSON() ([]byte, error) {
+	h, err := r.header()
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(replayJSON{header: h, Events: r.Events})
+}
+
+func (r *Replay) UnmarshalJSON(data []byte) error {
+	rj := replayJSON{header: newHeader()}
+	if err := json.Unmarshal(data, &rj); err != nil {
+		return err
+	}
+
+	decoded, err := fromHeader(rj.header)
+	if err != nil {
+		return err
+	}
+
+	*r = *decoded
+	r.Events = rj.Events
+	return r.validate()
+}
+
+// validate checks the events can be played: known inputs and actions, in order, before the last step.
+// Players only look at the next event, so one out of order would hold back every event after it.
+func (r *Replay) validate() error {
+	if len(r.Events) > maxEvents {
+		return fmt.Errorf("%d events are too many", len(r.Events))
+	}
+
+	step := 0
+	for i, e := range r.Events {
+		switch {
+		case e.Input < 0 || int(e.Input) >= len(engine.Inputs):
+			return fmt.Errorf("event %d has unknown input %d", i, e.Input)
+		case e.Action != engine.Action_Down && e.Action != engine.Action_Up:
+			return fmt.Errorf("event %d has unknown action %d", i, e.Action)
+		case e.Step < step:
+			return fmt.Errorf("event %d is before the event before it", i)
+		case e.Step >= r.Steps:
+			return fmt.Errorf("event %d is after the last step", i)
+		}
+		step = e.Step
+	}
+
+	return nil
+}
+
+//// Binary
+
+// WriteBinary writes the replay in the binary format
+func (r *Replay) WriteBinary(w io.Writer) error {
+	h, err := r.header()
+	if err != nil {
+		return err
+	}
+	headerData, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	buf := make([]byte, binary.MaxVarintLen64)
+	writeUvarint := func(v uint64) {
+		n := binary.PutUvarint(buf, v)
+		bw.Write(buf[:n])
+	}
+
+	bw.Write(magic)
+	writeUvarint(uint64(r.Version))
+	writeUvarint(uint64(len(headerData)))
+	bw.Write(headerData)
+
+	writeUvarint(uint64(len(r.Events)))
+	lastStep := 0
+	for _, e := range r.Events {
+		writeUvarint(uint64(e.Step - lastStep))
+		writeUvarint(uint64(e.Input))
+		writeUvarint(uint64(e.Action))
+		lastStep = e.Step
+	}
+
+	return bw.Flush()
+}
+
+// ReadBinary reads a replay in the binary format
+func ReadBinary(rd io.Reader) (*Replay, error) {
+	br := bufio.NewReader(rd)
+
+	m := make([]byte, len(magic))
+	if _, err := io.ReadFull(br, m); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(m, magic) {
+		return nil, errors.New("not a replay")
+	}
+
+	version, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, err
+	}
+	if int(version) > Version {
+		return nil, fmt.Errorf("replay version %d is newer than %d, update getris to play it", version, Version)
+	}
+
+	headerLength, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, err
+	}
+	if headerLength > maxHeader {
+		return nil, fmt.Errorf("header of %d bytes is too big", headerLength)
+	}
+	headerData := make([]byte, headerLength)
+	if _, err := io.ReadFull(br, headerData); err != nil {
+		return nil, err
+	}
+	h := newHeader()
+	if err := json.Unmarshal(headerData, &h); err != nil {
+		return nil, err
+	}
+	r, err := fromHeader(h)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, err
+	}
+	if count > maxEvents {
+		return nil, fmt.Errorf("%d events are too many", count)
+	}
+	step := 0
+	for i := uint64(0); i < count; i++ {
+		var fields [3]uint64
+		for j := range fields {
+			if fields[j], err = binary.ReadUvarint(br); err != nil {
+				return nil, err
+			}
+		}
+
+		// Checked here too, so adding it up can't overflow
+		if fields[0] >= uint64(r.Steps-step) {
+			return nil, fmt.Errorf("event %d is after the last step", i)
+		}
+
+		step += int(fields[0])
+		r.Events = append(r.Events, Event{
+			Step:   step,
+			Input:  engine.Input(fields[1]),
+			Action: engine.Action(fields[2]),
+		})
+	}
+
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+//// Files
+
+// Save writes the replay to path, as JSON if it ends in .json, otherwise in the binary format
+func (r *Replay) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	if filepath.Ext(path) == JSONExtension {
+		data, err := json.MarshalIndent(r, "", "\t")
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(path, data, 0o644)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := r.WriteBinary(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Load reads a replay in either format
+func Load(path string) (*Replay, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.HasPrefix(data, magic) {
+		return ReadBinary(bytes.NewReader(data))
+	}
+
+	r := &Replay{}
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
@@ -0,0 +1,123 @@
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"getris/engine"
+)
+
+// recorded plays a few steps of sprint, with some inputs
+func recorded() *Replay {
+	r := New(engine.DefaultRules(), engine.DefaultHandling(), engine.SprintMode{Lines: 40}, 1)
+	game := r.NewGame()
+	for step := 0; step < 120; step++ {
+		var inputs []engine.InputEvent
+		switch step % 30 {
+		case 0:
+			inputs = []engine.InputEvent{{Input: engine.Input_MoveLeft, Action: engine.Action_Down}}
+		case 10:
+			inputs = []engine.InputEvent{{Input: engine.Input_MoveLeft, Action: engine.Action_Up}}
+		case 20:
+			inputs = []engine.InputEvent{
+				{Input: engine.Input_HardDrop, Action: engine.Action_Down},
+				{Input: engine.Input_HardDrop, Action: engine.Action_Up},
+			}
+		}
+
+		r.Record(inputs)
+		game.Step(inputs)
+	}
+	r.Finish(game)
+
+	return r
+}
+
+func TestFormatsRoundTrip(t *testing.T) {
+	r := recorded()
+
+	var b bytes.Buffer
+	if err := r.WriteBinary(&b); err != nil {
+		t.Fatal(err)
+	}
+	fromBinary, err := ReadBinary(&b)
+	if err != nil {
+		t.Fatalf("ReadBinary: %v", err)
+	}
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromJSON := &Replay{}
+	if err := json.Unmarshal(data, fromJSON); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	for name, read := range map[string]*Replay{"binary": fromBinary, "json": fromJSON} {
+		if !reflect.DeepEqual(read.Events, r.Events) || read.Steps != r.Steps || read.Result != r.Result {
+			t.Errorf("%s replay read back differently", name)
+		}
+		if _, err := read.Verify(); err != nil {
+			t.Errorf("%s replay doesn't verify: %v", name, err)
+		}
+	}
+}
+
+func TestRejectedReplays(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(r *Replay)
+		want   string
+	}{
+		{"too many steps", func(r *Replay) { r.Steps = maxSteps + 1 }, "steps"},
+		{"negative steps", func(r *Replay) { r.Steps = -1 }, "steps"},
+		{"unknown input", func(r *Replay) { r.Events[0].Input = engine.Input(len(engine.Inputs)) }, "unknown input"},
+		{"unknown action", func(r *Replay) { r.Events[0].Action = 2 }, "unknown action"},
+		{"out of order", func(r *Replay) { r.Events[1].Step, r.Events[2].Step = r.Events[2].Step, r.Events[1].Step }, "before"},
+		{"after the last step", func(r *Replay) { r.Events[len(r.Events)-1].Step = r.Steps }, "after the last step"},
+		{"sprint without lines", func(r *Replay) { r.Mode = engine.SprintMode{} }, "lines"},
+		{"ultra without time", func(r *Replay) { r.Mode = engine.UltraMode{} }, "time limit"},
+		{"dig too tall", func(r *Replay) { r.Mode = engine.DigMode{Rows: 10, Height: 30} }, "height"},
+		{"dig too messy", func(r *Replay) { r.Mode = engine.DigMode{Rows: 10, Height: 5, Messiness: 2} }, "messiness"},
+		{"negative lock delay", func(r *Replay) { r.Rules.LockDelay = -1 }, "delays"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := recorded()
+			tt.change(r)
+
+			data, err := json.Marshal(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal(data, &Replay{}); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("JSON: got error %v, want one about %q", err, tt.want)
+			}
+
+			// The binary format can't hold events out of order
+			if tt.name == "out of order" {
+				return
+			}
+			var b bytes.Buffer
+			if err := r.WriteBinary(&b); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := ReadBinary(&b); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("binary: got error %v, want one about %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestBinaryHeaderTooBig(t *testing.T) {
+	data := append([]byte{}, magic...)
+	data = append(data, 1, 0xff, 0xff, 0xff, 0xff, 0x0f)
+	if _, err := ReadBinary(bytes.NewReader(data)); err == nil || !strings.Contains(err.Error(), "too big") {
+		t.Errorf("got error %v, want one about the header being too big", err)
+	}
+}
@@ -0,0 +1,73 @@
+package replay
+
+import (
+	"getris/engine"
+)
+
+// Player drives a game from a replay, instead of from the keyboard
+type Player struct {
+	Replay *Replay
+	Game   *engine.GameState
+
+	// step is the number of steps played
+	step int
+	// next is the index of the next event to play
+	next int
+}
+
+func NewPlayer(r *Replay) *Player {
+	return &Player{
+		Replay: r,
+		Game:   r.NewGame(),
+	}
+}
+
+// Step plays the next step of the replay.
+// Returns false, without doing anything, if the replay is over.
+func (p *Player) Step() bool {
+	if p.IsOver() {
+		return false
+	}
+
+	var inputs []engine.InputEvent
+	for p.next < len(p.Replay.Events) && p.Replay.Events[p.next].Step == p.step {
+		e := p.Replay.Events[p.next]
+		inputs = append(inputs, engine.InputEvent{Input: e.Input, Action: e.Action})
+		p.next++
+	}
+
+	p.Game.Step(inputs)
+	p.step++
+	return true
+}
+
+// Seek plays the replay up to the given step.
+// Going backwards starts the game over, and plays it up to the step again.
+func (p *Player) Seek(step int) {
+	if step < p.step {
+		p.Game = p.Replay.NewGame()
+		p.step = 0
+		p.next = 0
+	}
+
+	for p.step < step && p.Step() {
+	}
+}
+
+// Position returns the number of steps played
+func (p *Player) Position() int {
+	return p.step
+}
+
+// IsOver returns true once every step has been played
+func (p *Player) IsOver() bool {
+	return p.step >= p.Replay.Steps
+}
+
+// PlayAll plays the rest of the replay, and returns the game as it ended
+func (p *Player) PlayAll() *engine.GameState {
+	for p.Step() {
+	}
+
+	return p.Game
+}
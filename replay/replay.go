@@ -0,0 +1,123 @@
+// Package replay records the inputs of a game, so it can be played back exactly as it happened.
+// Games are deterministic, so a replay only needs the seed, the settings and the inputs on each step.
+package replay
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"getris/engine"
+)
+
+// Version is the replay format written by this version of getris.
+// Replays from newer versions are refused, older ones are still read.
+//
+// Versions:
+//   - 1: First version
+//   - 2: Result has the hash of the board
+//   - 3: Rules have the number of previews, and if hold is allowed
+//   - 4: Rules have the generation and row clear delays
+const Version int = 4
+
+type Replay struct {
+	Version int
+	Date    time.Time
+
+	Seed     int64
+	Rules    engine.Rules
+	Handling engine.Handling
+	Mode     engine.Mode
+
+	// Events holds every input, in the order they were given to the game
+	Events []Event
+	// Steps counts every step of the game, including the ones spent paused
+	Steps int
+
+	// Result is how the game ended
+	Result Result
+}
+
+// Event is an input, and the step it was given to the game on
+type Event struct {
+	Step   int           `json:"step"`
+	Input  engine.Input  `json:"input"`
+	Action engine.Action `json:"action"`
+}
+
+type Result struct {
+	Score  int `json:"score"`
+	Lines  int `json:"lines"`
+	Level  int `json:"level"`
+	Frames int `json:"frames"`
+	// BoardHash is the hash of the board as the game ended, 0 in replays before version 2.
+	// It's a string in JSON, so it isn't rounded by readers with only float64 numbers.
+	BoardHash uint64 `json:"boardHash,string"`
+}
+
+// resultOf returns how a game ended
+func resultOf(gs *engine.GameState) Result {
+	return Result{
+		Score:     gs.Score,
+		Lines:     gs.LinesCleared(),
+		Level:     gs.Level(),
+		Frames:    gs.Frames(),
+		BoardHash: gs.Board.Hash(),
+	}
+}
+
+// New starts recording a game, NewGame creates the game to record
+func New(rules engine.Rules, handling engine.Handling, mode engine.Mode, seed int64) *Replay {
+	return &Replay{
+		Version:  Version,
+		Date:     time.Now(),
+		Seed:     seed,
+		Rules:    rules,
+		Handling: handling,
+		Mode:     mode,
+	}
+}
+
+// NewGame creates the game as it was when the replay started
+func (r *Replay) NewGame() *engine.GameState {
+	return engine.NewGameState(r.Rules, r.Handling, r.Mode, r.Seed)
+}
+
+// Record adds the inputs for the next step.
+// It has to be called once for every step of the game, even without inputs.
+func (r *Replay) Record(inputs []engine.InputEvent) {
+	for _, input := range inputs {
+		r.Events = append(r.Events, Event{
+			Step:   r.Steps,
+			Input:  input.Input,
+			Action: input.Action,
+		})
+	}
+
+	r.Steps++
+}
+
+// Finish keeps how the game ended
+func (r *Replay) Finish(gs *engine.GameState) {
+	r.Result = resultOf(gs)
+}
+
+// Duration returns how long the replay lasts, including the pause
+func (r *Replay) Duration() time.Duration {
+	return engine.Duration(r.Steps)
+}
+
+// DefaultDir returns where replays are saved, in the user's config directory
+func DefaultDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "getris", "replays"), nil
+}
+
+// FileName returns a name for saving the replay, from its mode and date
+func (r *Replay) FileName() string {
+	return r.Mode.Name() + "-" + r.Date.Format("20060102-150405") + Extension
+}
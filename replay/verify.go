@@ -0,0 +1,97 @@
+package replay
+
+import (
+	"fmt"
+	"strings"
+
+	"getris/engine"
+)
+
+// Mismatch is a part of the result that the replay claims, but didn't happen when it was played
+type Mismatch struct {
+	Field           string
+	Claimed, Played string
+}
+
+// MismatchError is returned by Verify when the replay doesn't play out as it claims
+type MismatchError []Mismatch
+
+func (e MismatchError) Error() string {
+	parts := make([]string, len(e))
+	for i, m := range e {
+		parts[i] = fmt.Sprintf("%s: claimed %s, played %s", m.Field, m.Claimed, m.Played)
+	}
+
+	return "replay doesn't match its result: " + strings.Join(parts, "; ")
+}
+
+// Verify plays the whole replay, without drawing it, and compares how it ended to the claimed result.
+// Returns the played result, and a MismatchError if it's different.
+// The board hash is always compared, replays from before it was kept can't be verified.
+func (r *Replay) Verify() (Result, error) {
+	played := resultOf(NewPlayer(r).PlayAll())
+
+	var mismatches MismatchError
+	compare := func(field string, claimed, played interface{}) {
+		if claimed != played {
+			mismatches = append(mismatches, Mismatch{field, fmt.Sprint(claimed), fmt.Sprint(played)})
+		}
+	}
+	compare("score", r.Result.Score, played.Score)
+	compare("lines", r.Result.Lines, played.Lines)
+	compare("level", r.Result.Level, played.Level)
+	compare("frames", r.Result.Frames, played.Frames)
+	compare("board", fmt.Sprintf("%016x", r.Result.BoardHash), fmt.Sprintf("%016x", played.BoardHash))
+
+	if mismatches != nil {
+		return played, mismatches
+	}
+	return played, nil
+}
+
+// RuleChange is a rule the replay was played with that isn't the expected one
+type RuleChange struct {
+	Rule             string
+	Expected, Played string
+}
+
+// RulesError is returned by CheckRules when the replay wasn't played with the expected rules
+type RulesError []RuleChange
+
+func (e RulesError) Error() string {
+	parts := make([]string, len(e))
+	for i, c := range e {
+		parts[i] = fmt.Sprintf("%s: expected %s, played %s", c.Rule, c.Expected, c.Played)
+	}
+
+	return "replay wasn't played with the expected rules: " + strings.Join(parts, "; ")
+}
+
+// CheckRules compares the rules the replay was played with to the expected ones, after its mode changed them.
+// Returns a RulesError if any is different.
+func (r *Replay) CheckRules(expected engine.Rules) error {
+	played := engine.NewGameState(r.Rules, r.Handling, r.Mode, r.Seed).Rules
+	expected = engine.NewGameState(expected, r.Handling, r.Mode, r.Seed).Rules
+
+	var changes RulesError
+	compare := func(rule string, expected, played interface{}) {
+		if expected != played {
+			changes = append(changes, RuleChange{rule, fmt.Sprint(expected), fmt.Sprint(played)})
+		}
+	}
+	compare("randomizer", expected.Randomizer, played.Randomizer)
+	compare("rotation", expected.RotationSystem, played.RotationSystem)
+	compare("generation-delay", expected.GenerationDelay, played.GenerationDelay)
+	compare("row-clear-delay", expected.RowClearDelay, played.RowClearDelay)
+	compare("lock-delay", expected.LockDelay, played.LockDelay)
+	compare("lock-reset", expected.LockReset, played.LockReset)
+	compare("lock-resets", expected.LockResetLimit, played.LockResetLimit)
+	compare("scoring", expected.Scoring, played.Scoring)
+	compare("previews", expected.Previews, played.Previews)
+	compare("hold", expected.Hold, played.Hold)
+
+	if changes != nil {
+		return changes
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+
+	"getris/spectate"
+)
+
+// screen is what the window is showing: a menu, or a game being played
+type screen interface {
+	// Update is called once every rendered frame.
+	// Returns the screen to show next, itself to stay, or nil to quit.
+	Update() screen
+	// Draw is called from the render loop, after Update
+	Draw()
+}
+
+// sizedScreen is a screen that needs a different size of window than gameLayout's, like versus
+type sizedScreen interface {
+	screen
+	screenSize() (x, y int32)
+}
+
+// screenSizeOf returns the size of window that s needs
+func screenSizeOf(s screen) (x, y int32) {
+	if sized, ok := s.(sizedScreen); ok {
+		return sized.screenSize()
+	}
+
+	return gameLayout.screenSize()
+}
+
+// spectatedScreen is a screen showing games, which are streamed to watchers with --spectate-port
+type spectatedScreen interface {
+	screen
+	spectated() spectate.State
+}
+
+// spectatedStateOf returns the games s is showing, none if it isn't showing any
+func spectatedStateOf(s screen) spectate.State {
+	if spectated, ok := s.(spectatedScreen); ok {
+		return spectated.spectated()
+	}
+
+	return spectate.State{}
+}
+
+type menuItem struct {
+	label string
+	// value returns the setting shown next to the label, nil for items without one
+	value func() string
+	// change is called with -1 or 1 when left or right is pressed, nil if the item can't be changed
+	change func(delta int)
+	// selected is called when the item is chosen, returns the next screen
+	selected func() screen
+}
+
+// menu is a list of items, navigated with the arrow keys and enter
+type menu struct {
+	title  string
+	items  []menuItem
+	cursor int
+}
+
+// update moves the cursor and changes items.
+// Returns the next screen and true, if an item was chosen.
+func (m *menu) update() (screen, bool) {
+	// Menus don't use the key queue, empty it so keys aren't sent to the next game
+	for rl.GetKeyPressed() != 0 {
+	}
+
+	item := &m.items[m.cursor]
+	switch {
+	case rl.IsKeyPressed(rl.KeyUp):
+		m.cursor = (m.cursor + len(m.items) - 1) % len(m.items)
+	case rl.IsKeyPressed(rl.KeyDown):
+		m.cursor = (m.cursor + 1) % len(m.items)
+	case rl.IsKeyPressed(rl.KeyLeft):
+		if item.change != nil {
+			item.change(-1)
+		}
+	case rl.IsKeyPressed(rl.KeyRight):
+		if item.change != nil {
+			item.change(1)
+		}
+	case rl.IsKeyPressed(rl.KeyEnter), rl.IsKeyPressed(rl.KeySpace):
+		if item.selected != nil {
+			return item.selected(), true
+		}
+		if item.change != nil {
+			item.change(1)
+		}
+	}
+
+	return nil, false
+}
+
+// draw draws the title and items centered on the screen, starting at y
+func (m *menu) draw(y int32) {
+	drawCenteredTextLine(m.title, y, menuTitleTextSize, textColor)
+	y += menuTitleTextSize + menuLineSpacing
+
+	for i, item := range m.items {
+		text := item.label
+		if item.value != nil {
+			text += ": < " + item.value() + " >"
+		}
+
+		color := menuTextColor
+		if i == m.cursor {
+			color = menuSelectedTextColor
+		}
+
+		drawCenteredTextLine(text, y, menuTextSize, color)
+		y += menuTextSize + menuLineSpacing
+	}
+}
+
+// cycle moves an index by delta, wrapping around count
+func cycle(index, delta, count int) int {
+	return ((index+delta)%count + count) % count
+}
+
+// indexOf returns the index of value in values, 0 if it isn't there
+func indexOf(values []int, value int) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/user"
+	"time"
+
+	"getris/engine"
+	"getris/netplay"
+	"getris/records"
+	"getris/replay"
+)
+
+// settings are used for every new game.
+// They come from the command line, and can be changed on the settings screen.
+type settings struct {
+	rules    engine.Rules
+	handling engine.Handling
+
+	// seed is used for every game if hasSeed is true,
+	// otherwise every game gets a new seed
+	seed    int64
+	hasSeed bool
+
+	marathon engine.MarathonMode
+	// sprintLines is how many lines a sprint is to
+	sprintLines int
+	// ultraMinutes is how long an ultra lasts
+	ultraMinutes int
+	dig          engine.DigMode
+	zen          engine.ZenMode
+
+	// versus is the mode of both players in versus, and versusKeyMaps holds each player's keys
+	versus        engine.VersusMode
+	versusKeyMaps [engine.VersusPlayers]map[int32]engine.Input
+
+	// hostAddr is where online matches are hosted, and joinAddr is the host last joined.
+	// startHosting or startJoining show the host or join screen instead of the title screen.
+	hostAddr, joinAddr         string
+	startHosting, startJoining bool
+	// netDelay is the number of frames inputs wait in hosted matches, so they reach the other player in time
+	netDelay int
+
+	// spectatePort is where games are streamed to watchers, 0 if they aren't
+	spectatePort int
+
+	// gamepad is how gamepads are read, and input is where the inputs of every game come from
+	gamepad gamepadSettings
+	input   InputSource
+
+	// configPath is where the config was read from, and where changes to it are saved
+	configPath string
+
+	// name is kept with games in the records
+	name    string
+	records *records.Records
+
+	// replay is played instead of showing the title screen, if it isn't nil
+	replay *replay.Replay
+}
+
+// newSettings returns the defaults, before the config and flags
+func newSettings() *settings {
+	s := &settings{
+		rules:        engine.DefaultRules(),
+		handling:     engine.DefaultHandling(),
+		marathon:     engine.DefaultMarathonMode(),
+		sprintLines:  sprintLineGoals[1],
+		ultraMinutes: ultraMinuteLimits[1],
+		dig:          engine.DefaultDigMode(),
+		zen:          engine.ZenMode{Gravity: true},
+		versus:       engine.DefaultVersusMode(),
+		gamepad:      defaultGamepadSettings(),
+		hostAddr:     ":" + netplay.DefaultPort,
+		joinAddr:     "localhost:" + netplay.DefaultPort,
+		netDelay:     netplay.DefaultDelay,
+	}
+	for i, keyMap := range defaultVersusKeyMaps {
+		s.versusKeyMaps[i] = copyKeyMap(keyMap)
+	}
+	s.input = InputSources{KeyboardSource{}, NewGamepadSource(&s.gamepad)}
+
+	return s
+}
+
+// loadConfigAt reads the config at path, or the one in the config directory if path is empty.
+// Returns the path of the config, which doesn't have to exist unless it was given.
+func (s *settings) loadConfigAt(path string) (string, error) {
+	if path != "" {
+		return path, s.loadConfig(path, true)
+	}
+
+	path, err := defaultConfigPath()
+	if err != nil {
+		// Without a config directory, there's no config to read
+		return "", nil
+	}
+	return path, s.loadConfig(path, false)
+}
+
+// parseFlags reads the settings from the command line, exits if they're invalid
+func parseFlags() *settings {
+	s := newSettings()
+
+	var err error
+	exitOnError := func() {
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+
+	// The config sets the defaults for the flags
+	configPath, err := s.loadConfigAt(configFlagPath(os.Args[1:]))
+	exitOnError()
+
+	s.configPath = configPath
+	flag.String("config", configPath, "config file, read before the other flags")
+	writeConfig := flag.Bool("write-config", false, "write the settings, defaults and flags, as a new config file at --config, then exit")
+	flag.Func("seed", "seed for the tetromino randomizer, games with the same seed deal the same tetrominos", func(v string) (err error) {
+		_, err = fmt.Sscan(v, &s.seed)
+		s.hasSeed = err == nil
+		return err
+	})
+	randomizerName := flag.String("randomizer", s.rules.Randomizer.String(), "how tetrominos are dealt: 7-bag, 14-bag, classic, nes or tgm")
+	rotationName := flag.String("rotation", s.rules.RotationSystem.String(), "how tetrominos rotate: srs or classic")
+	flag.Func("generation-delay", "frames (12, 12f) or duration (200ms) before the next tetromino spawns", framesFlag(&s.rules.GenerationDelay))
+	flag.Func("row-clear-delay", "frames (5, 5f) or duration (83ms) cleared rows are shown before they're deleted", framesFlag(&s.rules.RowClearDelay))
+	flag.Func("lock-delay", "frames (30, 30f) or duration (500ms) a tetromino can rest on something before it locks", framesFlag(&s.rules.LockDelay))
+	lockResetName := flag.String("lock-reset", s.rules.LockReset.String(), "what restarts the lock delay: move, infinite or step")
+	flag.IntVar(&s.rules.LockResetLimit, "lock-resets", s.rules.LockResetLimit, "how many moves can restart the lock delay, with --lock-reset=move")
+	scoringName := flag.String("scoring", s.rules.Scoring.String(), "how points are awarded: guideline, nes or tgm")
+	flag.IntVar(&s.rules.Previews, "previews", s.rules.Previews, fmt.Sprintf("how many upcoming tetrominos are shown, up to %d", engine.TetrominoQueueSize))
+	flag.BoolVar(&s.rules.Hold, "hold", s.rules.Hold, "allow holding a tetromino")
+
+	flag.IntVar(&s.marathon.StartLevel, "marathon-start-level", s.marathon.StartLevel, fmt.Sprintf("level marathon starts on, from 1 to %d", engine.MarathonMaxLevel))
+	flag.IntVar(&s.marathon.EndLevel, "marathon-end-level", s.marathon.EndLevel, "level marathon is finished after, 0 is endless")
+	flag.Func("das", "frames (10, 10f) or duration (167ms) left or right is held before it repeats", framesFlag(&s.handling.DAS))
+	flag.Func("arr", "frames (2, 2f) or duration (33ms) between repeated moves, 0 moves straight to the wall", framesFlag(&s.handling.ARR))
+	flag.Func("dcd", "frames (0, 0f) or duration (0ms) auto shift waits after a spawn or rotation", framesFlag(&s.handling.DCD))
+	flag.IntVar(&s.handling.SDF, "sdf", s.handling.SDF, "how many times faster than gravity soft drop falls, 0 drops straight to the floor")
+	flag.StringVar(&s.name, "name", defaultName(), "name kept with your games in the records")
+	replayPath := flag.String("replay", "", "play back a replay file, instead of showing the title screen")
+	flag.Func("host", "host an online versus match, listening on the address (:7777)", func(v string) error {
+		s.hostAddr = v
+		s.startHosting = true
+		return nil
+	})
+	flag.Func("join", "join an online versus match at host:port", func(v string) error {
+		s.joinAddr = v
+		s.startJoining = true
+		return nil
+	})
+	flag.IntVar(&s.spectatePort, "spectate-port", 0, "stream the games being played to watchers on this port, 0 doesn't")
+	flag.IntVar(&s.netDelay, "net-delay", s.netDelay, "frames inputs wait in hosted online matches, so they reach the other player in time")
+	ghostStyleName := flag.String("ghost", drawGhostStyle.String(), "how the landing preview is drawn: filled, outline or off")
+	flag.Parse()
+
+	s.rules.Randomizer, err = engine.ParseRandomizerKind(*randomizerName)
+	exitOnError()
+	s.rules.RotationSystem, err = engine.ParseRotationSystemKind(*rotationName)
+	exitOnError()
+	s.rules.LockReset, err = engine.ParseLockResetKind(*lockResetName)
+	exitOnError()
+	s.rules.Scoring, err = engine.ParseScoringKind(*scoringName)
+	exitOnError()
+	drawGhostStyle, err = parseGhostStyle(*ghostStyleName)
+	exitOnError()
+	if s.handling.SDF < 0 {
+		err = fmt.Errorf("--sdf can't be negative")
+		exitOnError()
+	}
+	if s.rules.LockResetLimit < 0 {
+		err = fmt.Errorf("--lock-resets can't be negative")
+		exitOnError()
+	}
+	if s.rules.Previews < 0 || s.rules.Previews > engine.TetrominoQueueSize {
+		err = fmt.Errorf("--previews must be from 0 to %d", engine.TetrominoQueueSize)
+		exitOnError()
+	}
+
+	if err = s.marathon.Check(); err != nil {
+		err = fmt.Errorf("--marathon-start-level, --marathon-end-level: %w", err)
+		exitOnError()
+	}
+	if s.spectatePort < 0 || s.spectatePort > 65535 {
+		err = fmt.Errorf("--spectate-port must be from 0 to 65535")
+		exitOnError()
+	}
+	if s.netDelay < netplay.MinDelay {
+		err = fmt.Errorf("--net-delay must be at least %d", netplay.MinDelay)
+		exitOnError()
+	}
+	if s.startHosting && s.startJoining {
+		err = fmt.Errorf("--host and --join can't be used together")
+		exitOnError()
+	}
+
+	if *writeConfig {
+		err = s.writeConfig(configPath)
+		exitOnError()
+		fmt.Println("wrote", configPath)
+		os.Exit(0)
+	}
+
+	if *replayPath != "" {
+		s.replay, err = replay.Load(*replayPath)
+		exitOnError()
+	}
+
+	s.records = loadRecords()
+
+	return s
+}
+
+// loadRecords loads the personal bests.
+// If they can't be loaded, games are still played, but records are only kept until the window is closed.
+func loadRecords() *records.Records {
+	path, err := records.DefaultPath()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "records won't be saved:", err)
+		return records.New("")
+	}
+
+	r, err := records.Load(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "couldn't load records, they won't be saved:", err)
+	}
+
+	return r
+}
+
+// defaultName returns the name of the user logged in, or "player" if it isn't known
+func defaultName() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+
+	return "player"
+}
+
+// framesFlag parses a flag into a number of frames
+func framesFlag(frames *int) func(string) error {
+	return func(s string) (err error) {
+		*frames, err = engine.ParseFrames(s)
+		return err
+	}
+}
+
+// nextSeed returns the seed for the next game
+func (s *settings) nextSeed() int64 {
+	if s.hasSeed {
+		return s.seed
+	}
+
+	return time.Now().UnixNano()
+}
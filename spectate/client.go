@@ -0,0 +1,46 @@
+package spectate
+
+import (
+	"bufio"
+	"net"
+	"time"
+)
+
+// Client watches the games streamed by a server
+type Client struct {
+	conn  net.Conn
+	r     *bufio.Reader
+	state State
+}
+
+// Dial connects to a server, waiting up to timeout for it to answer
+func Dial(addr string, timeout time.Duration) (*Client, error) {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	r := bufio.NewReader(conn)
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	if err := readPreamble(r); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	return &Client{conn: conn, r: r}, nil
+}
+
+// Next waits for the games to change, and returns them as they are now
+func (c *Client) Next() (State, error) {
+	if err := decode(c.r, &c.state); err != nil {
+		return State{}, err
+	}
+
+	// Messages replace what they change instead of changing it, so only the list of games needs copying
+	return State{Games: append([]Game{}, c.state.Games...)}, nil
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
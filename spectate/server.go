@@ -0,0 +1,165 @@
+package spectate
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// watcherBuffer is how many messages can wait for a watcher before it's dropped for falling behind
+	watcherBuffer = 120
+	// writeTimeout is how long a watcher can take to read a message before it's dropped
+	writeTimeout = 5 * time.Second
+)
+
+// Server streams games to every watcher connected to it.
+// Publish is called by whatever owns the games, and never waits on watchers: ones that fall behind are dropped.
+type Server struct {
+	ln net.Listener
+
+	mu       sync.Mutex
+	watchers []*watcher
+	isClosed bool
+
+	// last is the state last published, it belongs to the publisher
+	last State
+}
+
+// watcher is a connection being streamed to
+type watcher struct {
+	conn net.Conn
+	out  chan []byte
+	// isNew is true until the watcher has been sent its first keyframe
+	isNew bool
+	// done is closed once nothing more can be written
+	done chan struct{}
+}
+
+// Listen starts streaming on the address, like ":7778"
+func Listen(addr string) (*Server, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{ln: ln}
+	go s.accept()
+	return s, nil
+}
+
+// Addr returns the address watchers connect to
+func (s *Server) Addr() net.Addr {
+	return s.ln.Addr()
+}
+
+func (s *Server) accept() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+
+		w := &watcher{
+			conn:  conn,
+			out:   make(chan []byte, watcherBuffer),
+			isNew: true,
+			done:  make(chan struct{}),
+		}
+
+		// A watcher accepted as the server closes would never be dropped
+		s.mu.Lock()
+		if s.isClosed {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		s.watchers = append(s.watchers, w)
+		s.mu.Unlock()
+
+		go w.write()
+	}
+}
+
+// write sends the preamble, then every message, until the connection fails or the watcher is dropped
+func (w *watcher) write() {
+	defer close(w.done)
+	defer w.conn.Close()
+
+	w.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if err := writePreamble(w.conn); err != nil {
+		return
+	}
+	for message := range w.out {
+		w.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		if _, err := w.conn.Write(message); err != nil {
+			return
+		}
+	}
+}
+
+// drop stops streaming to the watcher
+func (w *watcher) drop() {
+	w.conn.Close()
+	close(w.out)
+}
+
+// Publish sends watchers what changed since the last state published.
+// The state mustn't be changed after, like one from Capture.
+func (s *Server) Publish(state State) {
+	delta, isChanged := encode(nil, s.last, state)
+	s.last = state
+
+	var keyframe []byte
+	hasKeyframe := false
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	watchers := s.watchers[:0]
+	for _, w := range s.watchers {
+		select {
+		case <-w.done:
+			close(w.out)
+			continue
+		default:
+		}
+
+		message, ok := delta, isChanged
+		if w.isNew {
+			// Watchers start with no games, so their first message is every game from scratch
+			if !hasKeyframe {
+				keyframe, _ = encode(nil, State{}, state)
+				hasKeyframe = true
+			}
+			message, ok = keyframe, len(keyframe) > 0
+			w.isNew = false
+		}
+		if ok {
+			select {
+			case w.out <- message:
+			default:
+				w.drop()
+				continue
+			}
+		}
+
+		watchers = append(watchers, w)
+	}
+	s.watchers = watchers
+}
+
+// Close stops listening, and drops every watcher
+func (s *Server) Close() error {
+	err := s.ln.Close()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.isClosed = true
+	for _, w := range s.watchers {
+		w.drop()
+	}
+	s.watchers = nil
+
+	return err
+}
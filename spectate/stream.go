@@ -0,0 +1,458 @@
+// Package spectate streams games as they're played to watchers, who draw them without playing.
+// Only what changed since the last update is sent, so a stream is a few bytes a frame.
+package spectate
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"getris/engine"
+)
+
+// The stream is:
+//
+// Preamble. When a watcher connects, the server writes:
+//   - magic, the 4 bytes "GTSP"
+//   - the stream version, as a uvarint
+//
+// Messages. Everything after the preamble is a message, sent by the server whenever the games change:
+//   - the type, as a byte
+//   - the length of the payload, as a uvarint, then the payload
+//
+// The messages are:
+//   - keyframe (1): every game, from scratch. Sent first, and whenever the number of games changes.
+//   - delta (2): what changed in each game since the last message.
+//
+// Both have the same payload, the number of games as a uvarint, then each game:
+//   - the fields that changed, as a uvarint of the field bits below
+//   - each field that changed, in the order of its bit
+//
+// In a keyframe, fields are changes from an empty game: an empty board, no tetrominos, 0 points and Phase_Generation.
+//
+// Fields:
+//   - board (1): the number of cells that changed as a uvarint, then each cell in order of its index (y*10 + x):
+//     the number of cells skipped since the last one as a uvarint, and the cell as a byte,
+//     1 if filled, 2 if a ghost, and the kind shifted left by 2.
+//   - active (2): a byte, 0 if there's no active tetromino. Otherwise 1, then its kind and rotation as uvarints,
+//     its origin x and y as varints, and the x and y of each of its 4 cells as varints.
+//   - hold (4): the kind held, as a uvarint.
+//   - queue (8): the number of upcoming kinds shown as a uvarint, then each kind as a uvarint.
+//   - score (16), lines (32), level (64) and phase (128): each as a uvarint.
+
+// Version is the stream version. Watchers refuse streams from other versions.
+const Version = 1
+
+var magic = []byte("GTSP")
+
+type messageType byte
+
+const (
+	message_Keyframe messageType = iota + 1
+	message_Delta
+)
+
+type field uint64
+
+const (
+	field_Board field = 1 << iota
+	field_Active
+	field_Hold
+	field_Queue
+	field_Score
+	field_Lines
+	field_Level
+	field_Phase
+)
+
+const (
+	// maxPayload is the largest payload read, so a bad server can't make us allocate anything bigger
+	maxPayload = 1 << 16
+	// maxGames is the most games a stream can have at once
+	maxGames = 8
+)
+
+// Game is what's shown of a game being played
+type Game struct {
+	Board  engine.Board
+	Active *engine.Tetromino
+	Hold   engine.Kind
+	// Queue holds the upcoming kinds that are shown, the next one is first
+	Queue []engine.Kind
+
+	Score, Lines, Level int
+	Phase               engine.Phase
+}
+
+// State is every game being shown, like both players' in versus.
+// It has no games while nothing is being played.
+type State struct {
+	Games []Game
+}
+
+// Capture copies what's shown of the games
+func Capture(games ...*engine.GameState) State {
+	s := State{Games: make([]Game, len(games))}
+	for i, gs := range games {
+		g := &s.Games[i]
+		g.Board = gs.Board
+		if gs.ActiveTetromino != nil {
+			active := *gs.ActiveTetromino
+			g.Active = &active
+		}
+		g.Hold = gs.HoldingTetromino
+		g.Queue = append([]engine.Kind{}, gs.Previews()...)
+		g.Score = gs.Score
+		g.Lines = gs.LinesCleared()
+		g.Level = gs.Level()
+		g.Phase = gs.Phase
+	}
+
+	return s
+}
+
+// changes returns the fields of next that are different from prev
+func changes(prev, next *Game) field {
+	var f field
+	if prev.Board != next.Board {
+		f |= field_Board
+	}
+	if (prev.Active == nil) != (next.Active == nil) || (next.Active != nil && *prev.Active != *next.Active) {
+		f |= field_Active
+	}
+	if prev.Hold != next.Hold {
+		f |= field_Hold
+	}
+	if len(prev.Queue) != len(next.Queue) {
+		f |= field_Queue
+	} else {
+		for i := range next.Queue {
+			if prev.Queue[i] != next.Queue[i] {
+				f |= field_Queue
+				break
+			}
+		}
+	}
+	if prev.Score != next.Score {
+		f |= field_Score
+	}
+	if prev.Lines != next.Lines {
+		f |= field_Lines
+	}
+	if prev.Level != next.Level {
+		f |= field_Level
+	}
+	if prev.Phase != next.Phase {
+		f |= field_Phase
+	}
+
+	return f
+}
+
+// encode appends the message that takes a watcher from prev to next.
+// It's a keyframe if prev has a different number of games. Returns false if nothing changed.
+func encode(buf []byte, prev, next State) ([]byte, bool) {
+	kind := message_Delta
+	base := prev.Games
+	if len(prev.Games) != len(next.Games) {
+		kind = message_Keyframe
+		base = make([]Game, len(next.Games))
+	}
+
+	var e encoder
+	e.uvarint(uint64(len(next.Games)))
+	isChanged := kind == message_Keyframe
+	for i := range next.Games {
+		if e.game(&base[i], &next.Games[i]) != 0 {
+			isChanged = true
+		}
+	}
+	if !isChanged {
+		return buf, false
+	}
+
+	return appendMessage(buf, kind, e.buf), true
+}
+
+func appendMessage(buf []byte, kind messageType, payload []byte) []byte {
+	buf = append(buf, byte(kind))
+	buf = appendUvarint(buf, uint64(len(payload)))
+	return append(buf, payload...)
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var b [binary.MaxVarintLen64]byte
+	return append(buf, b[:binary.PutUvarint(b[:], v)]...)
+}
+
+func appendVarint(buf []byte, v int64) []byte {
+	var b [binary.MaxVarintLen64]byte
+	return append(buf, b[:binary.PutVarint(b[:], v)]...)
+}
+
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) uvarint(v uint64) {
+	e.buf = appendUvarint(e.buf, v)
+}
+
+func (e *encoder) varint(v int64) {
+	e.buf = appendVarint(e.buf, v)
+}
+
+// game writes the fields of next that are different from prev, and returns them
+func (e *encoder) game(prev, next *Game) field {
+	f := changes(prev, next)
+	e.uvarint(uint64(f))
+
+	if f&field_Board != 0 {
+		var cells []int
+		for y := range next.Board {
+			for x := range next.Board[y] {
+				if prev.Board[y][x] != next.Board[y][x] {
+					cells = append(cells, y*int(engine.BoardCellsX)+x)
+				}
+			}
+		}
+
+		e.uvarint(uint64(len(cells)))
+		last := -1
+		for _, index := range cells {
+			e.uvarint(uint64(index - last - 1))
+			e.buf = append(e.buf, encodeCell(next.Board[index/int(engine.BoardCellsX)][index%int(engine.BoardCellsX)]))
+			last = index
+		}
+	}
+	if f&field_Active != 0 {
+		if t := next.Active; t == nil {
+			e.buf = append(e.buf, 0)
+		} else {
+			e.buf = append(e.buf, 1)
+			e.uvarint(uint64(t.Kind))
+			e.uvarint(uint64(t.Rotation))
+			e.varint(int64(t.OriginX))
+			e.varint(int64(t.OriginY))
+			for _, cell := range t.Cells {
+				e.varint(int64(cell[0]))
+				e.varint(int64(cell[1]))
+			}
+		}
+	}
+	if f&field_Hold != 0 {
+		e.uvarint(uint64(next.Hold))
+	}
+	if f&field_Queue != 0 {
+		e.uvarint(uint64(len(next.Queue)))
+		for _, kind := range next.Queue {
+			e.uvarint(uint64(kind))
+		}
+	}
+	for _, v := range []struct {
+		field field
+		value int
+	}{{field_Score, next.Score}, {field_Lines, next.Lines}, {field_Level, next.Level}, {field_Phase, int(next.Phase)}} {
+		if f&v.field != 0 {
+			e.uvarint(uint64(v.value))
+		}
+	}
+
+	return f
+}
+
+func encodeCell(c engine.Cell) byte {
+	b := byte(c.Kind) << 2
+	if c.IsFilled {
+		b |= 1
+	}
+	if c.IsGhost {
+		b |= 2
+	}
+	return b
+}
+
+func decodeCell(b byte) (engine.Cell, error) {
+	kind := engine.Kind(b >> 2)
+	if kind > engine.Kind_Garbage {
+		return engine.Cell{}, fmt.Errorf("unknown kind %d", kind)
+	}
+
+	return engine.Cell{IsFilled: b&1 != 0, IsGhost: b&2 != 0, Kind: kind}, nil
+}
+
+// writePreamble writes the magic and version
+func writePreamble(w io.Writer) error {
+	_, err := w.Write(appendUvarint(append([]byte{}, magic...), Version))
+	return err
+}
+
+// readPreamble reads the magic and version, and returns an error if it isn't a stream this version can read
+func readPreamble(r *bufio.Reader) error {
+	m := make([]byte, len(magic))
+	if _, err := io.ReadFull(r, m); err != nil {
+		return err
+	}
+	if !bytes.Equal(m, magic) {
+		return errors.New("not a getris spectator stream")
+	}
+
+	version, err := binary.ReadUvarint(r)
+	if err != nil {
+		return err
+	}
+	if version != Version {
+		return fmt.Errorf("the stream is version %d, this is version %d", version, Version)
+	}
+	return nil
+}
+
+// decode reads a message, and applies it to s
+func decode(r *bufio.Reader, s *State) error {
+	kind, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	length, err := binary.ReadUvarint(r)
+	if err != nil {
+		return err
+	}
+	if length > maxPayload {
+		return fmt.Errorf("message of %d bytes is too big", length)
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return err
+	}
+
+	d := decoder{r: bytes.NewReader(payload)}
+	count := int(d.uvarint(maxGames))
+	switch messageType(kind) {
+	case message_Keyframe:
+		s.Games = make([]Game, count)
+	case message_Delta:
+		if count != len(s.Games) {
+			return fmt.Errorf("delta for %d games, there are %d", count, len(s.Games))
+		}
+	default:
+		return fmt.Errorf("unknown message %d", kind)
+	}
+
+	for i := range s.Games {
+		d.game(&s.Games[i])
+	}
+	return d.err
+}
+
+// decoder reads a payload, keeping the first error
+type decoder struct {
+	r   *bytes.Reader
+	err error
+}
+
+// uvarint reads a uvarint no bigger than max
+func (d *decoder) uvarint(max uint64) uint64 {
+	if d.err != nil {
+		return 0
+	}
+
+	v, err := binary.ReadUvarint(d.r)
+	if err == nil && v > max {
+		err = fmt.Errorf("%d is out of range", v)
+	}
+	if err != nil {
+		d.err = err
+		return 0
+	}
+	return v
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+
+	v, err := binary.ReadVarint(d.r)
+	if err != nil {
+		d.err = err
+	}
+	return v
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil {
+		return 0
+	}
+
+	b, err := d.r.ReadByte()
+	if err != nil {
+		d.err = err
+	}
+	return b
+}
+
+func (d *decoder) kind() engine.Kind {
+	return engine.Kind(d.uvarint(uint64(engine.Kind_Garbage)))
+}
+
+func (d *decoder) game(g *Game) {
+	f := field(d.uvarint(uint64(field_Phase<<1 - 1)))
+
+	if f&field_Board != 0 {
+		count := d.uvarint(uint64(engine.BoardCellsX * engine.BoardCellsY))
+		index := -1
+		for i := uint64(0); i < count && d.err == nil; i++ {
+			index += int(d.uvarint(uint64(engine.BoardCellsX*engine.BoardCellsY))) + 1
+			cell, err := decodeCell(d.byte())
+			if err == nil && index >= int(engine.BoardCellsX*engine.BoardCellsY) {
+				err = fmt.Errorf("cell %d is off the board", index)
+			}
+			if err != nil && d.err == nil {
+				d.err = err
+			}
+			if d.err != nil {
+				return
+			}
+			g.Board[index/int(engine.BoardCellsX)][index%int(engine.BoardCellsX)] = cell
+		}
+	}
+	if f&field_Active != 0 {
+		g.Active = nil
+		if d.byte() == 1 {
+			t := &engine.Tetromino{}
+			t.Kind = d.kind()
+			t.Rotation = engine.Rotation(d.uvarint(uint64(engine.Rotation_Left)))
+			t.OriginX = int32(d.varint())
+			t.OriginY = int32(d.varint())
+			for i := range t.Cells {
+				t.Cells[i][0] = int32(d.varint())
+				t.Cells[i][1] = int32(d.varint())
+			}
+			g.Active = t
+		}
+	}
+	if f&field_Hold != 0 {
+		g.Hold = d.kind()
+	}
+	if f&field_Queue != 0 {
+		g.Queue = make([]engine.Kind, d.uvarint(uint64(engine.TetrominoQueueSize)))
+		for i := range g.Queue {
+			g.Queue[i] = d.kind()
+		}
+	}
+	if f&field_Score != 0 {
+		g.Score = int(d.uvarint(1 << 62))
+	}
+	if f&field_Lines != 0 {
+		g.Lines = int(d.uvarint(1 << 62))
+	}
+	if f&field_Level != 0 {
+		g.Level = int(d.uvarint(1 << 62))
+	}
+	if f&field_Phase != 0 {
+		g.Phase = engine.Phase(d.uvarint(uint64(engine.Phase_End)))
+	}
+}
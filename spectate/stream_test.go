@@ -0,0 +1,123 @@
+package spectate
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"getris/engine"
+)
+
+// watch decodes every message in stream, starting from no games
+func watch(t *testing.T, stream []byte) State {
+	t.Helper()
+
+	var s State
+	r := bufio.NewReader(bytes.NewReader(stream))
+	for {
+		if _, err := r.Peek(1); err != nil {
+			return s
+		}
+		if err := decode(r, &s); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+	}
+}
+
+// checkSame fails unless a watcher would draw got like want
+func checkSame(t *testing.T, frame int, got, want State) {
+	t.Helper()
+
+	if len(got.Games) != len(want.Games) {
+		t.Fatalf("frame %d: decoded %d games, want %d", frame, len(got.Games), len(want.Games))
+	}
+	for i := range want.Games {
+		if f := changes(&got.Games[i], &want.Games[i]); f != 0 {
+			t.Fatalf("frame %d: game %d decoded with different fields %b", frame, i, f)
+		}
+	}
+}
+
+func TestStreamRoundTrip(t *testing.T) {
+	gs := engine.NewGameState(engine.DefaultRules(), engine.DefaultHandling(), engine.MarathonMode{StartLevel: 1, EndLevel: 15}, 5)
+
+	var stream []byte
+	var prev State
+	var keyframes, deltas, phases int
+	for frame := 0; frame < 600; frame++ {
+		var inputs []engine.InputEvent
+		switch frame % 40 {
+		case 5:
+			inputs = []engine.InputEvent{{Input: engine.Input_MoveLeft, Action: engine.Action_Down}}
+		case 15:
+			inputs = []engine.InputEvent{{Input: engine.Input_MoveLeft, Action: engine.Action_Up}}
+		case 20:
+			inputs = []engine.InputEvent{{Input: engine.Input_RotateClockwise, Action: engine.Action_Down}}
+		case 30:
+			inputs = []engine.InputEvent{{Input: engine.Input_Hold, Action: engine.Action_Down}}
+		}
+		gs.Step(inputs)
+
+		next := Capture(gs)
+		var ok bool
+		before := len(stream)
+		if stream, ok = encode(stream, prev, next); ok {
+			switch messageType(stream[before]) {
+			case message_Keyframe:
+				keyframes++
+			case message_Delta:
+				deltas++
+				if changes(&prev.Games[0], &next.Games[0])&field_Phase != 0 {
+					phases++
+				}
+			}
+		}
+		prev = next
+
+		checkSame(t, frame, watch(t, stream), next)
+	}
+
+	if keyframes != 1 || deltas == 0 || phases == 0 {
+		t.Errorf("streamed %d keyframes, %d deltas and %d phase changes, want 1 keyframe and some of each", keyframes, deltas, phases)
+	}
+}
+
+func TestStreamKeyframes(t *testing.T) {
+	a := engine.NewGameState(engine.DefaultRules(), engine.DefaultHandling(), engine.DefaultVersusMode(), 1)
+	b := engine.NewGameState(engine.DefaultRules(), engine.DefaultHandling(), engine.DefaultVersusMode(), 2)
+	for i := 0; i < 30; i++ {
+		a.Step(nil)
+		b.Step(nil)
+	}
+
+	// The games come and go, like a match starting and ending
+	states := []State{Capture(a), Capture(a, b), Capture(a, b), {}, Capture(b)}
+	states[2].Games[1].Board[0][0] = engine.Cell{IsFilled: true, Kind: engine.Kind_Garbage}
+	states[2].Games[1].Active = nil
+
+	var stream []byte
+	var prev State
+	for i, next := range states {
+		var ok bool
+		before := len(stream)
+		stream, ok = encode(stream, prev, next)
+		if !ok {
+			t.Fatalf("state %d: nothing was encoded", i)
+		}
+
+		want := message_Delta
+		if len(prev.Games) != len(next.Games) {
+			want = message_Keyframe
+		}
+		if kind := messageType(stream[before]); kind != want {
+			t.Errorf("state %d: encoded message %d, want %d", i, kind, want)
+		}
+
+		checkSame(t, i, watch(t, stream), next)
+		prev = next
+	}
+
+	if _, ok := encode(nil, prev, prev); ok {
+		t.Errorf("encoded a message without changes")
+	}
+}
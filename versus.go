@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+
+	"getris/engine"
+	"getris/spectate"
+)
+
+//// Versus
+
+// versusScreen plays a match between two players side by side.
+// Each player has their own keys, and the gamepad with their number.
+type versusScreen struct {
+	settings *settings
+	match    *engine.Versus
+	runner   *engine.Runner
+	sources  InputSources
+	polled   []engine.InputEvent
+
+	layouts          [engine.VersusPlayers]layout
+	screenX, screenY int32
+
+	// wins counts the matches each player has won, since the first match was started from the menu
+	wins [engine.VersusPlayers]int
+}
+
+func newVersusScreen(s *settings, wins [engine.VersusPlayers]int) *versusScreen {
+	var handling [engine.VersusPlayers]engine.Handling
+	for i := range handling {
+		handling[i] = s.handling
+	}
+
+	match := engine.NewVersus(s.rules, handling, s.versus, s.nextSeed())
+	runner := engine.NewRunner(match)
+	runner.MaxLag = maxUnsimulatedTime
+
+	vs := &versusScreen{
+		settings: s,
+		match:    match,
+		runner:   runner,
+		wins:     wins,
+	}
+	vs.layouts, vs.screenX, vs.screenY = versusLayouts(gameLayout.cellSize)
+	for i, keyMap := range s.versusKeyMaps {
+		vs.sources = append(vs.sources, PlayerSource{
+			Source: InputSources{NewKeyboardSource(keyMap), NewGamepadSource(&s.gamepad, int32(i))},
+			Player: i,
+		})
+	}
+
+	return vs
+}
+
+func (vs *versusScreen) Update() screen {
+	// Leave the match from the pause screen
+	if vs.match.IsPaused && rl.IsKeyPressed(quitGameKey) {
+		return newModeSelectScreen(vs.settings)
+	}
+
+	vs.polled = vs.sources.Poll(vs.polled[:0])
+	vs.runner.Push(vs.polled...)
+	vs.runner.Advance(time.Duration(float64(rl.GetFrameTime()) * float64(time.Second)))
+
+	if vs.match.IsDone() {
+		if vs.match.Winner != engine.NoWinner {
+			vs.wins[vs.match.Winner]++
+		}
+		s, wins := vs.settings, vs.wins
+		return newVersusOverScreen(s, vs.match, wins, func() screen { return newVersusScreen(s, wins) })
+	}
+
+	return vs
+}
+
+func (vs *versusScreen) Draw() {
+	drawVersus(vs.match, &vs.layouts, func(i int, gs *engine.GameState) []scoreLine {
+		return []scoreLine{
+			{label: linesText, value: fmt.Sprint(gs.LinesCleared())},
+			{label: sentText, value: fmt.Sprint(gs.GarbageSent())},
+			{label: winsText, value: fmt.Sprint(vs.wins[i])},
+		}
+	})
+}
+
+// drawVersus draws both players' games with the score lines for each, who won once the match is over,
+// and the pause screen
+func drawVersus(match *engine.Versus, layouts *[engine.VersusPlayers]layout, score func(player int, gs *engine.GameState) []scoreLine) {
+	for i, gs := range match.Players {
+		l := &layouts[i]
+		l.drawBoards(gs, score(i, gs))
+
+		if match.IsOver {
+			switch match.Winner {
+			case i:
+				drawCenteredText(winText, l.centerX)
+			case engine.NoWinner:
+				drawCenteredText(drawnText, l.centerX)
+			default:
+				drawCenteredText(loseText, l.centerX)
+			}
+		}
+	}
+
+	if match.IsPaused {
+		drawCenteredText(pausedText, 0)
+		drawCenteredTextLine(quitGameText, int32(titleTextSize), menuTextSize, textColor)
+	}
+}
+
+func (vs *versusScreen) screenSize() (x, y int32) {
+	return vs.screenX, vs.screenY
+}
+
+func (vs *versusScreen) spectated() spectate.State {
+	return spectate.Capture(vs.match.Players[:]...)
+}
+
+//// Versus over
+
+// versusOverScreen shows who won the match, and offers a rematch if it can be played
+type versusOverScreen struct {
+	menu    menu
+	summary []string
+}
+
+// newVersusOverScreen shows the end of a match. rematch starts the next match, it's nil if there can't be one.
+func newVersusOverScreen(s *settings, match *engine.Versus, wins [engine.VersusPlayers]int, rematch func() screen) *versusOverScreen {
+	title := drawnText
+	if match.Winner != engine.NoWinner {
+		title = fmt.Sprintf("%s %d %s", playerText, match.Winner+1, winsText)
+	}
+
+	summary := []string{fmt.Sprintf("%s %d - %d", winsText, wins[0], wins[1])}
+	for i, gs := range match.Players {
+		summary = append(summary, fmt.Sprintf("%s %d  %s %d  %s %d", playerText, i+1, linesText, gs.LinesCleared(), sentText, gs.GarbageSent()))
+	}
+
+	var items []menuItem
+	if rematch != nil {
+		items = append(items, menuItem{label: "Rematch", selected: rematch})
+	}
+	items = append(items, menuItem{label: "Menu", selected: func() screen { return newTitleScreen(s) }})
+
+	return &versusOverScreen{
+		summary: summary,
+		menu:    menu{title: title, items: items},
+	}
+}
+
+func (vos *versusOverScreen) Update() screen {
+	if next, ok := vos.menu.update(); ok {
+		return next
+	}
+
+	return vos
+}
+
+func (vos *versusOverScreen) Draw() {
+	vos.menu.draw(menuTopY)
+
+	y := menuTopY + menuTitleTextSize + menuLineSpacing + int32(len(vos.menu.items))*(menuTextSize+menuLineSpacing) + menuLineSpacing
+	for _, line := range vos.summary {
+		drawCenteredTextLine(line, y, menuTextSize, textColor)
+		y += menuTextSize + menuLineSpacing
+	}
+}
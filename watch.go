@@ -0,0 +1,183 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+
+	"getris/engine"
+	"getris/spectate"
+)
+
+const (
+	// watchTimeout is how long watching waits for the server to answer
+	watchTimeout = 5 * time.Second
+	// watchRetryInterval is how long watching waits before connecting again, after the stream ends
+	watchRetryInterval = 2 * time.Second
+)
+
+// watch shows the games streamed from another getris with --spectate-port, without playing them.
+// Returns the exit code.
+func watch(args []string) int {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	configPath := fs.String("config", "", "config file with the colors and cell size, instead of the one in your config directory")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: getris watch [--config <file>] <host:port>")
+		fmt.Fprintln(fs.Output(), "Shows the games streamed by a getris started with --spectate-port.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	// Only the visuals of the config matter to watching
+	s := newSettings()
+	if _, err := s.loadConfigAt(*configPath); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	runWindow(nil, func() screen { return newWatchScreen(fs.Arg(0)) })
+	return 0
+}
+
+// watchUpdate is the games after a message from the server, or why the stream ended
+type watchUpdate struct {
+	state spectate.State
+	err   error
+}
+
+// watchScreen draws the games streamed from a server, and connects again whenever the stream ends
+type watchScreen struct {
+	addr    string
+	updates chan watchUpdate
+
+	isConnected bool
+	err         error
+
+	// games are drawn, with the fields from the stream replaced on every update
+	games []*engine.GameState
+	state spectate.State
+
+	layouts          [engine.VersusPlayers]layout
+	screenX, screenY int32
+}
+
+func newWatchScreen(addr string) *watchScreen {
+	ws := &watchScreen{addr: addr, updates: make(chan watchUpdate, 60)}
+	ws.layouts, ws.screenX, ws.screenY = versusLayouts(gameLayout.cellSize)
+
+	go ws.read()
+	return ws
+}
+
+// read passes on the games from the server, for as long as the window is open
+func (ws *watchScreen) read() {
+	for {
+		c, err := spectate.Dial(ws.addr, watchTimeout)
+		if err == nil {
+			// Watchers start with no games, until the first message
+			ws.updates <- watchUpdate{}
+			for {
+				var state spectate.State
+				if state, err = c.Next(); err != nil {
+					break
+				}
+				ws.updates <- watchUpdate{state: state}
+			}
+			c.Close()
+		}
+
+		ws.updates <- watchUpdate{err: err}
+		time.Sleep(watchRetryInterval)
+	}
+}
+
+func (ws *watchScreen) Update() screen {
+	if rl.IsKeyPressed(rl.KeyEscape) {
+		return nil
+	}
+
+	// Only the latest games are drawn
+	for len(ws.updates) > 0 {
+		u := <-ws.updates
+		ws.isConnected = u.err == nil
+		ws.err = u.err
+		ws.state = u.state
+	}
+
+	for len(ws.games) < len(ws.state.Games) {
+		rules := engine.DefaultRules()
+		rules.Hold = true
+		ws.games = append(ws.games, engine.NewGameState(rules, engine.DefaultHandling(), engine.ZenMode{}, 0))
+	}
+	for i, g := range ws.state.Games {
+		gs := ws.games[i]
+		gs.Board = g.Board
+		gs.ActiveTetromino = g.Active
+		gs.HoldingTetromino = g.Hold
+		gs.Rules.Previews = copy(gs.TetrominoQueue[:], g.Queue)
+		gs.Score = g.Score
+		gs.Phase = g.Phase
+	}
+
+	return ws
+}
+
+func (ws *watchScreen) Draw() {
+	switch {
+	case !ws.isConnected:
+		drawCenteredTextLine(watchingText, menuTopY, menuTitleTextSize, textColor)
+		y := menuTopY + menuTitleTextSize + menuLineSpacing
+		drawCenteredTextLine(ws.addr, y, menuTextSize, textColor)
+		if ws.err != nil {
+			drawCenteredTextLine(ws.err.Error(), y+menuTextSize+menuLineSpacing, menuTextSize, textColor)
+		} else {
+			drawCenteredTextLine("Connecting...", y+menuTextSize+menuLineSpacing, menuTextSize, textColor)
+		}
+		return
+	case len(ws.state.Games) == 0:
+		drawCenteredTextLine(watchingText, menuTopY, menuTitleTextSize, textColor)
+		drawCenteredTextLine("Waiting for a game", menuTopY+menuTitleTextSize+menuLineSpacing, menuTextSize, textColor)
+		return
+	}
+
+	for i, g := range ws.state.Games {
+		l := &gameLayout
+		if len(ws.state.Games) > 1 {
+			if i >= len(ws.layouts) {
+				break
+			}
+			l = &ws.layouts[i]
+		}
+
+		l.drawBoards(ws.games[i], []scoreLine{
+			{label: levelText, value: fmt.Sprint(g.Level)},
+			{label: scoreText, value: fmt.Sprint(g.Score)},
+			{label: linesText, value: fmt.Sprint(g.Lines)},
+		})
+
+		switch g.Phase {
+		case engine.Phase_Paused:
+			drawCenteredText(pausedText, l.centerX)
+		case engine.Phase_GameOver:
+			drawCenteredText(gameOverText, l.centerX)
+		case engine.Phase_Finished:
+			drawCenteredText(finishedText, l.centerX)
+		}
+	}
+}
+
+// screenSize fits side by side boards while more than one game is streamed
+func (ws *watchScreen) screenSize() (x, y int32) {
+	if len(ws.state.Games) > 1 {
+		return ws.screenX, ws.screenY
+	}
+
+	return gameLayout.screenSize()
+}